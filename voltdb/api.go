@@ -2,61 +2,381 @@ package voltdb
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Conn is a single connection to a single node of a VoltDB database
+// Conn is a single connection to a single node of a VoltDB database.
+// A background goroutine reads responses from the server and hands
+// each to the pending call with the matching client handle.
+//
+// A Conn is safe for concurrent use by multiple goroutines. Each
+// invocation is written whole under a lock, and responses, which the
+// server may send in any order, are matched to calls by handle, so
+// concurrent calls are pipelined on the one socket.
 type Conn struct {
-	tcpConn  *net.TCPConn
+	netConn  io.ReadWriteCloser
 	connData *connectionData
+	handle   int64     // last client handle issued, updated atomically
+	stats    ConnStats // updated atomically
+	maxRsp   int64     // response size limit, 0 for maxMessageSize; updated atomically
+
+	dial     func() (io.ReadWriteCloser, error) // opens a new socket to the server
+	fixed    bool                               // made by NewConn, so dial can not open another
+	loginMsg []byte                             // serialized login, replayed on reconnect
+	scheme   HashScheme                         // password hash scheme of loginMsg
+	retry    *RetryPolicy
+
+	writeMu      sync.Mutex // serializes writes to and replacement of netConn
+	reconnectMu  sync.Mutex // serializes reconnect attempts
+	readers      sync.WaitGroup
+	mu           sync.Mutex // protects the fields below, and netConn and connData with writeMu
+	pending      map[int64]*Future
+	abandoned    map[int64]bool // handles of calls given up on whose responses are yet to arrive
+	drained      chan struct{}  // closed when no calls remain pending, for Drain
+	err          error          // why the response reader stopped, if it has
+	gen          int            // incremented by each reconnect
+	closed       bool
+	validUTF8    bool          // passed on to each received Table
+	keepalive    chan struct{} // closed to stop the heartbeat
+	nextHandle   func() int64  // handle generator, nil for the counter
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	rounding     DecimalRounding
+	compression  Compression   // passed on to each received Table
+	slots        chan struct{} // holds a token per outstanding call, nil for no limit
+	debug        io.Writer     // receives a dump of each frame, nil for none
+	logger       Logger        // receives internal events, nil for none
+	replay       bool          // resend idempotent calls lost to a dropped connection
+
+	debugMu sync.Mutex // serializes writes to debug
 }
 
+// ErrClosed is the error of calls made on, or still pending when, a
+// Conn is closed.
+var ErrClosed = errors.New("Conn is closed.")
+
 // connectionData are the values returned by a successful login.
 type connectionData struct {
-	hostId      int32
-	connId      int64
-	leaderAddr  int32
-	buildString string
+	hostId       int32
+	connId       int64
+	clusterStart int64 // milliseconds since the Unix epoch
+	leaderAddr   int32
+	buildString  string
 }
 
-// NewConn creates an initialized, authenticated Conn.
+// ServerInfo describes the server a Conn is logged in to.
+type ServerInfo struct {
+	HostID       int32
+	ConnectionID int64
+	// ClusterStartTime and LeaderAddr together identify the
+	// cluster instance.
+	ClusterStartTime time.Time
+	LeaderAddr       net.IP
+	BuildString      string
+}
+
+// NewConnection creates an initialized, authenticated Conn. The
+// password is sent as a SHA-256 hash, and if the server rejects that
+// login, as one that predates SHA-256 does, as a SHA-1 hash over a
+// new socket. Use NewConnectionWithScheme to choose the scheme.
 func NewConnection(user string, passwd string, hostAndPort string) (*Conn, error) {
-	var conn = new(Conn)
-	var err error
-	var raddr *net.TCPAddr
-	var login bytes.Buffer
+	return connectDefault(tcpDialer(hostAndPort), user, passwd)
+}
+
+// NewConnectionWithScheme creates an initialized, authenticated Conn
+// that hashes the password with scheme, without falling back to
+// another scheme.
+func NewConnectionWithScheme(user string, passwd string, hostAndPort string, scheme HashScheme) (*Conn, error) {
+	return connect(tcpDialer(hostAndPort), user, passwd, scheme)
+}
 
-	if raddr, err = net.ResolveTCPAddr("tcp", hostAndPort); err != nil {
-		return nil, fmt.Errorf("Error resolving %v.", hostAndPort)
+// tcpDialer returns a dial function opening TCP sockets to hostAndPort.
+func tcpDialer(hostAndPort string) func() (io.ReadWriteCloser, error) {
+	return func() (io.ReadWriteCloser, error) {
+		raddr, err := net.ResolveTCPAddr("tcp", hostAndPort)
+		if err != nil {
+			return nil, fmt.Errorf("Error resolving %v.", hostAndPort)
+		}
+		return net.DialTCP("tcp", nil, raddr)
 	}
-	if conn.tcpConn, err = net.DialTCP("tcp", nil, raddr); err != nil {
+}
+
+// ConnectTLS creates an initialized, authenticated Conn over TLS.
+// The TLS handshake completes before the wire protocol login begins.
+// If config does not name a server, the host in hostAndPort is used.
+func ConnectTLS(hostAndPort string, user string, passwd string, config *tls.Config) (*Conn, error) {
+	dial := func() (io.ReadWriteCloser, error) {
+		return tls.Dial("tcp", hostAndPort, config)
+	}
+	return connectDefault(dial, user, passwd)
+}
+
+// ConnectWithDialer creates an initialized, authenticated Conn
+// whose socket, and those of any reconnects, are opened with dialer,
+// which sets the connect timeout, keepalive and local address. The
+// login proceeds over the dialed connection.
+func ConnectWithDialer(dialer *net.Dialer, hostAndPort string, user string, passwd string) (*Conn, error) {
+	dial := func() (io.ReadWriteCloser, error) {
+		return dialer.Dial("tcp", hostAndPort)
+	}
+	return connectDefault(dial, user, passwd)
+}
+
+// ConnectCluster creates an initialized, authenticated Conn to the
+// first of hosts that accepts a connection. If that connection is
+// later lost, the Conn fails over to the following hosts in turn.
+// ConnectCluster installs a default RetryPolicy; see Call for which
+// calls are retried after a failover.
+func ConnectCluster(hosts []string, user string, passwd string) (*Conn, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("ConnectCluster needs at least one host.")
+	}
+	dialer := &clusterDialer{hosts: hosts}
+	conn, err := connectDefault(dialer.dial, user, passwd)
+	if err != nil {
 		return nil, err
 	}
-	if login, err = serializeLoginMessage(user, passwd); err != nil {
+	conn.retry = &RetryPolicy{
+		MaxRetries:  len(hosts),
+		BaseBackoff: 10 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+	return conn, nil
+}
+
+// clusterDialer dials the hosts of a cluster in turn. Each dial
+// starts with the host after the last one dialed successfully, so a
+// reconnect fails over to the next host.
+type clusterDialer struct {
+	hosts []string
+	next  int
+}
+
+func (d *clusterDialer) dial() (io.ReadWriteCloser, error) {
+	var err error
+	for i := 0; i < len(d.hosts); i++ {
+		idx := (d.next + i) % len(d.hosts)
+		var netConn net.Conn
+		if netConn, err = net.Dial("tcp", d.hosts[idx]); err == nil {
+			d.next = idx + 1
+			return netConn, nil
+		}
+	}
+	return nil, err
+}
+
+// NewConn creates an initialized, authenticated Conn that speaks the
+// wire protocol over rwc, which may be any transport such as a unix
+// socket or an in-memory pipe. Read and write timeouts apply only if
+// rwc has SetReadDeadline and SetWriteDeadline methods, as a net.Conn
+// does. rwc cannot be redialed, so the Conn does not reconnect or
+// reauthenticate, the password is sent only as a SHA-256 hash, and
+// rwc is closed if login fails.
+func NewConn(rwc io.ReadWriteCloser, user string, passwd string) (*Conn, error) {
+	dialed := false
+	dial := func() (io.ReadWriteCloser, error) {
+		if dialed {
+			return nil, fmt.Errorf("Conn made by NewConn cannot reconnect.")
+		}
+		dialed = true
+		return rwc, nil
+	}
+	conn, err := connect(dial, user, passwd, SHA256)
+	if err != nil {
 		return nil, err
 	}
-	if err = conn.writeMessage(login); err != nil {
+	conn.fixed = true
+	return conn, nil
+}
+
+// connectDefault connects as connect does with SHA-256 and, if the
+// server refuses that login or hangs up on it, once more with SHA-1,
+// so that servers that predate SHA-256 can still be reached.
+func connectDefault(dial func() (io.ReadWriteCloser, error), user string, passwd string) (*Conn, error) {
+	conn, err := connect(dial, user, passwd, SHA256)
+	if errors.Is(err, errAuthentication) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return connect(dial, user, passwd, SHA1)
+	}
+	return conn, err
+}
+
+// connect dials, authenticates and starts the response reader.
+func connect(dial func() (io.ReadWriteCloser, error), user string, passwd string, scheme HashScheme) (*Conn, error) {
+	var conn = &Conn{dial: dial, scheme: scheme}
+	var err error
+	var msg bytes.Buffer
+
+	if msg, err = serializeLoginMessage(user, passwd, scheme); err != nil {
 		return nil, err
 	}
-	if conn.connData, err = conn.readLoginResponse(); err != nil {
+	conn.loginMsg = msg.Bytes()
+	if conn.netConn, conn.connData, err = conn.login(conn.loginMsg); err != nil {
 		return nil, err
 	}
+	conn.pending = make(map[int64]*Future)
+	// a seeded counter does not repeat the handles of earlier Conns.
+	conn.handle = time.Now().UnixNano()
+	conn.readers.Add(1)
+	go conn.readResponses(conn.netConn, conn.gen)
 	return conn, nil
 }
 
+// login dials a new socket and authenticates on it with loginMsg.
+// The socket is closed if login fails.
+func (conn *Conn) login(loginMsg []byte) (io.ReadWriteCloser, *connectionData, error) {
+	netConn, err := conn.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = writeMessage(netConn, loginMsg); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	connData, err := readLoginResponse(netConn)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	return netConn, connData, nil
+}
+
+// Reauthenticate logs in as user over a newly dialed socket, for
+// example to refresh credentials, and once the server accepts the
+// login replaces the Conn's socket with it, as a reconnect does. Later
+// reconnects log in as user too. It fails if calls are pending, and
+// calls made meanwhile wait for it to finish. If the server rejects
+// the login, the Conn is left logged in as before.
+//
+// The login is not repeated over the existing socket because the
+// server takes every message after the first login on a socket for
+// an invocation. For the same reason a Conn made by NewConn, whose
+// transport can not be redialed, can not reauthenticate.
+func (conn *Conn) Reauthenticate(user string, passwd string) error {
+	if conn.fixed {
+		return fmt.Errorf("Conn made by NewConn cannot reauthenticate.")
+	}
+	msg, err := serializeLoginMessage(user, passwd, conn.scheme)
+	if err != nil {
+		return err
+	}
+	conn.reconnectMu.Lock()
+	defer conn.reconnectMu.Unlock()
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+
+	conn.mu.Lock()
+	switch {
+	case conn.closed || conn.netConn == nil:
+		conn.mu.Unlock()
+		return ErrClosed
+	case conn.err != nil:
+		err = conn.err
+		conn.mu.Unlock()
+		return err
+	case len(conn.pending) > 0:
+		conn.mu.Unlock()
+		return fmt.Errorf("Can not reauthenticate with %d calls pending.", len(conn.pending))
+	}
+	conn.mu.Unlock()
+
+	netConn, connData, err := conn.login(msg.Bytes())
+	if err != nil {
+		return err
+	}
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		netConn.Close()
+		return ErrClosed
+	}
+	old := conn.netConn
+	conn.netConn = netConn
+	conn.connData = connData
+	conn.loginMsg = msg.Bytes()
+	conn.gen++
+	conn.err = nil
+	// the responses of abandoned calls went with the old socket.
+	conn.abandoned = nil
+	gen := conn.gen
+	conn.readers.Add(1)
+	conn.mu.Unlock()
+
+	old.Close()
+	go conn.readResponses(netConn, gen)
+	return nil
+}
+
 // Close a connection if open. A Conn, once closed, has no further use.
-// To open a new connection, use NewConnection.
+// To open a new connection, use NewConnection. Calls still pending
+// fail with ErrClosed. Closing a closed Conn does nothing.
 func (conn *Conn) Close() error {
-	var err error = nil
-	if conn.tcpConn != nil {
-		err = conn.tcpConn.Close()
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return nil
+	}
+	conn.closed = true
+	if conn.keepalive != nil {
+		close(conn.keepalive)
+		conn.keepalive = nil
 	}
-	conn.tcpConn = nil
+	gen := conn.gen
+	conn.mu.Unlock()
+	// fail pending calls before the reader sees the socket close.
+	conn.fail(gen, ErrClosed)
+
+	var err error
+	conn.writeMu.Lock()
+	if conn.netConn != nil {
+		err = conn.netConn.Close()
+	}
+	conn.mu.Lock()
+	conn.netConn = nil
 	conn.connData = nil
+	conn.mu.Unlock()
+	conn.writeMu.Unlock()
+	conn.readers.Wait()
 	return err
 }
 
+// SetValidateUTF8 sets whether the STRING values of tables received
+// from now on are checked to be valid UTF-8; see Table.SetValidateUTF8.
+func (conn *Conn) SetValidateUTF8(validate bool) {
+	conn.mu.Lock()
+	conn.validUTF8 = validate
+	conn.mu.Unlock()
+}
+
+// ServerInfo returns what the server reported at login, or nil if the
+// Conn is closed. After a reconnect it describes the new server.
+func (conn *Conn) ServerInfo() *ServerInfo {
+	conn.mu.Lock()
+	connData := conn.connData
+	conn.mu.Unlock()
+	if connData == nil {
+		return nil
+	}
+	leader := make(net.IP, 4)
+	order.PutUint32(leader, uint32(connData.leaderAddr))
+	return &ServerInfo{
+		HostID:           connData.hostId,
+		ConnectionID:     connData.connId,
+		ClusterStartTime: time.UnixMilli(connData.clusterStart).UTC(),
+		LeaderAddr:       leader,
+		BuildString:      connData.buildString,
+	}
+}
+
 // GoString provides a default printable format for Conn.
 func (conn *Conn) GoString() string {
 	if conn.connData != nil {
@@ -67,83 +387,553 @@ func (conn *Conn) GoString() string {
 	return "uninitialized"
 }
 
-// Ping the database for liveness.
-func (conn *Conn) TestConnection() bool {
-	if conn.tcpConn == nil {
-		return false
-	}
+// Ping checks that the database is reachable by calling @Ping. It
+// returns nil on success and the call's error otherwise.
+func (conn *Conn) Ping() error {
 	rsp, err := conn.Call("@Ping")
 	if err != nil {
-		return false
+		return err
+	}
+	if rsp.Status() != SUCCESS {
+		return fmt.Errorf("Ping failed: %v %v", rsp.Status(), rsp.StatusString())
 	}
-	return rsp.Status() == SUCCESS
+	return nil
+}
+
+// Ping the database for liveness.
+func (conn *Conn) TestConnection() bool {
+	return conn.Ping() == nil
 }
 
 // Call invokes the procedure 'procedure' with parameter values 'params'
-// and returns a pointer to the received Response.
+// and returns a pointer to the received Response. If the Conn has a
+// RetryPolicy, a lost connection is re-established. The call itself
+// is only retried if it had not yet been sent; see CallIdempotent.
 func (conn *Conn) Call(procedure string, params ...interface{}) (*Response, error) {
-	var call bytes.Buffer
-	var resp *bytes.Buffer
+	return conn.call(procedure, params, newCallOptions())
+}
+
+// CallContext is Call bounded by ctx. If ctx is done before the
+// response arrives, CallContext returns ctx.Err() and the response
+// is discarded when it arrives. The write of the invocation honors
+// the ctx deadline; the response is read by the connection's shared
+// reader, so the socket read deadline is left alone.
+func (conn *Conn) CallContext(ctx context.Context, procedure string, params ...interface{}) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	future, err := conn.send(ctx, procedure, params, newCallOptions(), nil)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-future.done:
+		return future.rsp, future.err
+	case <-ctx.Done():
+		conn.abandon(future.handle)
+		return nil, ctx.Err()
+	}
+}
+
+// CallAsync invokes the procedure 'procedure' with parameter values
+// 'params' without waiting for the response. The returned Future
+// yields the Response once it arrives.
+func (conn *Conn) CallAsync(procedure string, params ...interface{}) (*Future, error) {
+	return conn.send(context.Background(), procedure, params, newCallOptions(), nil)
+}
+
+// CallWithCallback invokes the procedure 'procedure' with parameter
+// values 'params' and returns once the invocation is written. cb is
+// called with the Response when it arrives, or with the error if the
+// connection is lost first. cb runs on the Conn's response reader,
+// which it holds up, so it must be quick and must not wait for other
+// calls on the Conn. A panic in cb stops the reader and so fails the
+// connection. If CallWithCallback returns an error, cb is not called.
+func (conn *Conn) CallWithCallback(procedure string, params []interface{}, cb func(*Response, error)) error {
+	if cb == nil {
+		return fmt.Errorf("CallWithCallback needs a callback.")
+	}
+	_, err := conn.send(context.Background(), procedure, params, newCallOptions(), cb)
+	return err
+}
+
+// Exec calls a procedure that modifies rows, such as an INSERT,
+// UPDATE or DELETE, and returns the number of rows modified, which
+// the server reports as a single BIGINT in the first result table.
+func (conn *Conn) Exec(procedure string, params ...interface{}) (int64, error) {
+	rsp, err := conn.Call(procedure, params...)
+	if err != nil {
+		return 0, err
+	}
+	if rsp.Status() != SUCCESS {
+		return 0, fmt.Errorf("%v failed: %v %v", procedure, rsp.Status(), rsp.StatusString())
+	}
+	if len(rsp.tables) == 0 {
+		return 0, fmt.Errorf("%v returned no results.", procedure)
+	}
+	table := rsp.Table(0)
+	if table.ColumnCount() != 1 || table.ColumnType(0) != vt_LONG || table.RowCount() != 1 {
+		return 0, fmt.Errorf("%v did not return a modified row count.", procedure)
+	}
+	if !table.AdvanceRow() {
+		return 0, protocolError(ErrTruncatedMessage, "%v row count is malformed.", procedure)
+	}
+	count, isNull, err := table.GetLong(0)
+	if err != nil {
+		return 0, err
+	}
+	if isNull {
+		return 0, fmt.Errorf("%v returned a NULL modified row count.", procedure)
+	}
+	return count, nil
+}
+
+// Invocation is one stored procedure call of a batch. Idempotent
+// marks a call safe to run more than once; see SetReplayOnReconnect.
+type Invocation struct {
+	Procedure  string
+	Args       []interface{}
+	Idempotent bool
+}
+
+// CallBatch sends every call before waiting for any response, so the
+// batch costs about one round trip. Responses are returned in the
+// order of calls. If a call fails, its response is nil and the first
+// error is returned once the calls already sent have completed.
+func (conn *Conn) CallBatch(calls []Invocation) ([]*Response, error) {
+	futures := make([]*Future, 0, len(calls))
 	var err error
+	for _, call := range calls {
+		opts := newCallOptions()
+		opts.idempotent = call.Idempotent
+		var future *Future
+		if future, err = conn.send(context.Background(), call.Procedure, call.Args, opts, nil); err != nil {
+			break
+		}
+		futures = append(futures, future)
+	}
+	rsps := make([]*Response, len(calls))
+	for idx, future := range futures {
+		rsp, getErr := future.Get()
+		if getErr != nil && err == nil {
+			err = getErr
+		}
+		rsps[idx] = rsp
+	}
+	return rsps, err
+}
+
+// maxProcedureNameLength bounds the length in bytes of a procedure
+// name, as VoltDB bounds the names of catalog objects.
+const maxProcedureNameLength = 1024
+
+// checkProcedureName rejects a procedure name the server could not
+// know before a round trip is spent on it.
+func checkProcedureName(procedure string) error {
+	if procedure == "" {
+		return fmt.Errorf("Procedure name is empty.")
+	}
+	if len(procedure) > maxProcedureNameLength {
+		return fmt.Errorf("Procedure name of %d bytes exceeds the limit of %d.",
+			len(procedure), maxProcedureNameLength)
+	}
+	return nil
+}
 
-	if conn.tcpConn == nil {
-		return nil, fmt.Errorf("Can not call procedure on closed Conn.")
+// Drain waits until no calls are pending, every Future having been
+// resolved and every callback having returned, and returns nil. If
+// the connection fails or is closed first, it returns the error its
+// pending calls failed with. Calls made while Drain waits are waited
+// for too.
+func (conn *Conn) Drain() error {
+	conn.mu.Lock()
+	if len(conn.pending) > 0 && conn.err == nil {
+		if conn.drained == nil {
+			conn.drained = make(chan struct{})
+		}
+		drained := conn.drained
+		conn.mu.Unlock()
+		<-drained
+		conn.mu.Lock()
+	}
+	defer conn.mu.Unlock()
+	if conn.closed {
+		return ErrClosed
 	}
+	return conn.err
+}
 
-	// Use 0 for handle; it's not necessary in pure sync client.
-	if call, err = serializeCall(procedure, 0, params); err != nil {
+// signalDrained wakes the callers of Drain if no calls are pending.
+// conn.mu must be held.
+func (conn *Conn) signalDrained() {
+	if conn.drained != nil && len(conn.pending) == 0 {
+		close(conn.drained)
+		conn.drained = nil
+	}
+}
+
+// send writes an invocation and registers its Future. The deadline
+// of ctx bounds the write, and ctx ends any wait for an outstanding
+// call slot. A non-nil callback is called with the result in place
+// of resolving the Future.
+func (conn *Conn) send(ctx context.Context, procedure string, params []interface{},
+	opts callOptions, callback func(*Response, error)) (*Future, error) {
+	future, err := conn.writeCall(ctx, procedure, params, opts, callback)
+	if err != nil {
+		atomic.AddInt64(&conn.stats.Errors, 1)
+	}
+	return future, err
+}
+
+// writeCall is send without the counting of failed calls.
+func (conn *Conn) writeCall(ctx context.Context, procedure string, params []interface{},
+	opts callOptions, callback func(*Response, error)) (*Future, error) {
+	if err := checkProcedureName(procedure); err != nil {
 		return nil, err
 	}
-	if err := conn.writeMessage(call); err != nil {
+	var err error
+
+	handle := conn.newHandle()
+	conn.mu.Lock()
+	rounding, compression, slots := conn.rounding, conn.compression, conn.slots
+	replay := conn.replay && opts.idempotent
+	conn.mu.Unlock()
+	if rounding == RoundHalfEven {
+		params = roundDecimals(params)
+	}
+	if compression == Gzip {
+		if params, err = compressParams(params); err != nil {
+			return nil, err
+		}
+	}
+	call, err := newInvocationEncoder(opts.queryTimeout)
+	if err != nil {
 		return nil, err
 	}
-	if resp, err = conn.readMessage(); err != nil {
+	if err = serializeCall(call, procedure, handle, params); err != nil {
 		return nil, err
 	}
-	return deserializeCallResponse(resp)
+	future := &Future{handle: handle, callback: callback}
+	if replay {
+		future.replay = call.frame()
+	}
+	if callback == nil {
+		future.done = make(chan struct{})
+	}
+	if slots != nil && !opts.reserved {
+		select {
+		case slots <- struct{}{}:
+			future.slots = slots
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	registered := false
+	defer func() {
+		if !registered {
+			future.release()
+		}
+	}()
+	writeDeadline, _ := ctx.Deadline()
+
+	var dropped *lostCalls
+	conn.writeMu.Lock()
+	defer func() {
+		// failing calls may run callbacks that call into the Conn.
+		conn.writeMu.Unlock()
+		conn.failLost(dropped)
+	}()
+	if conn.netConn == nil {
+		return nil, ErrClosed
+	}
+	conn.mu.Lock()
+	if conn.err != nil {
+		err = conn.err
+		conn.mu.Unlock()
+		if lost, ok := err.(*connectionError); ok {
+			err = &connectionError{lost.err, false}
+		}
+		return nil, err
+	}
+	if _, ok := conn.pending[handle]; ok || conn.abandoned[handle] {
+		conn.mu.Unlock()
+		return nil, fmt.Errorf("Client handle %d is already in use.", handle)
+	}
+	conn.pending[handle] = future
+	registered = true
+	if len(conn.pending) == 1 {
+		conn.updateReadDeadline()
+	}
+	gen := conn.gen
+	if writeDeadline.IsZero() && conn.writeTimeout > 0 {
+		writeDeadline = time.Now().Add(conn.writeTimeout)
+	}
+	conn.mu.Unlock()
+
+	frame := call.frame()
+	conn.dumpFrame("sent", frame)
+	if !writeDeadline.IsZero() {
+		setWriteDeadline(conn.netConn, writeDeadline)
+	}
+	err = call.flush(conn.netConn)
+	if !writeDeadline.IsZero() {
+		setWriteDeadline(conn.netConn, time.Time{})
+	}
+	if err == nil {
+		atomic.AddInt64(&conn.stats.CallsSent, 1)
+		atomic.AddInt64(&conn.stats.BytesWritten, int64(len(frame)))
+	}
+	if err != nil {
+		conn.abandon(handle)
+		// part of the invocation may have been written, even if the
+		// write timed out, so the stream can not be trusted.
+		err = &connectionError{err, true}
+		dropped = conn.lose(gen, err)
+		conn.netConn.Close()
+		return nil, err
+	}
+	return future, nil
+}
+
+// DecimalRounding says what a Conn does with a DECIMAL parameter
+// that has more than 12 fractional digits.
+type DecimalRounding int
+
+const (
+	// RoundingError fails the call.
+	RoundingError DecimalRounding = iota
+	// RoundHalfEven rounds the value with RoundDecimal.
+	RoundHalfEven
+)
+
+// SetDecimalRounding sets how *big.Rat parameters with more than 12
+// fractional digits are handled. The default is RoundingError.
+// Values beyond 38 digits of precision are always an error.
+func (conn *Conn) SetDecimalRounding(rounding DecimalRounding) {
+	conn.mu.Lock()
+	conn.rounding = rounding
+	conn.mu.Unlock()
+}
+
+// roundDecimals returns params with each *big.Rat rounded by
+// RoundDecimal. params itself is left unchanged.
+func roundDecimals(params []interface{}) []interface{} {
+	var rounded []interface{}
+	for idx, param := range params {
+		d, ok := param.(*big.Rat)
+		if !ok {
+			continue
+		}
+		if rounded == nil {
+			rounded = append([]interface{}(nil), params...)
+		}
+		rounded[idx] = RoundDecimal(d)
+	}
+	if rounded == nil {
+		return params
+	}
+	return rounded
+}
+
+// SetMaxOutstanding limits the calls awaiting a response to n, so
+// that a client faster than the server cannot queue unbounded work.
+// Once n calls are outstanding, further calls block until a response
+// frees a slot; CallContext stops waiting when its ctx is done.
+// Zero or less means no limit. A new limit applies to calls made
+// after it is set.
+func (conn *Conn) SetMaxOutstanding(n int) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if n <= 0 {
+		conn.slots = nil
+	} else {
+		conn.slots = make(chan struct{}, n)
+	}
+}
+
+// SetMaxResponseSize makes the Conn refuse responses longer than n
+// bytes, so that a corrupt or hostile length prefix can not make it
+// allocate more. A refused response fails the connection with an
+// ErrResponseTooLarge error. n of zero or less restores the default
+// of 50MB.
+func (conn *Conn) SetMaxResponseSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&conn.maxRsp, int64(n))
+}
+
+// responseLimit returns the longest response the Conn accepts.
+func (conn *Conn) responseLimit() int {
+	if n := atomic.LoadInt64(&conn.maxRsp); n > 0 {
+		return int(n)
+	}
+	return maxMessageSize
+}
+
+// SetHandleGenerator makes the Conn take client handles from next,
+// for example to use timestamps or random values. next must not
+// return the handle of a call still pending, nor that of a call given
+// up on, as by CallTimeout, until its late response has arrived and
+// been discarded; such a call fails. A nil next restores the
+// default, a counter that is not reset by reconnects.
+func (conn *Conn) SetHandleGenerator(next func() int64) {
+	conn.mu.Lock()
+	conn.nextHandle = next
+	conn.mu.Unlock()
+}
+
+// newHandle returns the client handle for a new call.
+func (conn *Conn) newHandle() int64 {
+	conn.mu.Lock()
+	next := conn.nextHandle
+	conn.mu.Unlock()
+	if next != nil {
+		return next()
+	}
+	return atomic.AddInt64(&conn.handle, 1)
+}
+
+// failed reports whether the connection has been lost.
+func (conn *Conn) failed() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.err != nil
+}
+
+// abandon forgets the pending call with the given handle. A response
+// that later arrives for it is discarded, and until then the handle
+// is not reused.
+func (conn *Conn) abandon(handle int64) {
+	conn.mu.Lock()
+	if future, ok := conn.pending[handle]; ok {
+		delete(conn.pending, handle)
+		future.release()
+		if conn.abandoned == nil {
+			conn.abandoned = make(map[int64]bool)
+		}
+		conn.abandoned[handle] = true
+	}
+	if len(conn.pending) == 0 {
+		conn.updateReadDeadline()
+		conn.signalDrained()
+	}
+	conn.mu.Unlock()
+}
+
+// Future is the eventual result of an asynchronous procedure call.
+type Future struct {
+	handle   int64
+	done     chan struct{}
+	rsp      *Response
+	err      error
+	callback func(*Response, error) // called instead of closing done
+	slots    chan struct{}          // outstanding call slot to release, if any
+	replay   []byte                 // invocation to resend after a reconnect, if idempotent
+	replays  int                    // times the invocation has been resent
+}
+
+// Get blocks until the response to the call arrives or the
+// connection fails.
+func (f *Future) Get() (*Response, error) {
+	<-f.done
+	return f.rsp, f.err
+}
+
+// Handle returns the client handle the call was sent with. The
+// Response to the call reports the same ClientHandle.
+func (f *Future) Handle() int64 {
+	return f.handle
+}
+
+// release frees the outstanding call slot held by f, if any.
+func (f *Future) release() {
+	if f.slots != nil {
+		<-f.slots
+		f.slots = nil
+	}
+}
+
+func (f *Future) resolve(rsp *Response, err error) {
+	f.release()
+	if f.callback != nil {
+		f.callback(rsp, err)
+		return
+	}
+	f.rsp = rsp
+	f.err = err
+	close(f.done)
 }
 
 // Response is a stored procedure result.
 type Response struct {
-	clientData      int64
-	fieldsPresent   uint8
-	status          int8
-	statusString    string
-	appStatus       int8
-	appStatusString string
-	clusterLatency  int32
-	exceptionLength int32
-	exceptionBytes  []byte
-	resultCount     int16
-	tables          []Table
+	clientData       int64
+	fieldsPresent    uint8
+	status           int8
+	statusString     string
+	appStatus        int8
+	appStatusString  string
+	clusterRoundTrip int32
+	exceptionLength  int32
+	exceptionBytes   []byte
+	resultCount      int16
+	tables           []Table
 }
 
 // Response status codes
 type Status int
 
 const (
-	SUCCESS            Status = 1
-	USER_ABORT         Status = -1
-	GRACEFUL_FAILURE   Status = -2
-	UNEXPECTED_FAILURE Status = -3
-	CONNECTION_LOST    Status = -4
+	SUCCESS             Status = 1
+	USER_ABORT          Status = -1
+	GRACEFUL_FAILURE    Status = -2
+	UNEXPECTED_FAILURE  Status = -3
+	CONNECTION_LOST     Status = -4
+	SERVER_UNAVAILABLE  Status = -5
+	CONNECTION_TIMEOUT  Status = -6
+	RESPONSE_UNKNOWN    Status = -7
+	TXN_RESTART         Status = -8
+	OPERATIONAL_FAILURE Status = -9
 )
 
+// UNINITIALIZED_APP_STATUS_CODE is the AppStatus of a response whose
+// procedure did not set an application status.
+const UNINITIALIZED_APP_STATUS_CODE = -128
+
+// UNINITIALIZED_TABLE_STATUS_CODE is the StatusCode of a table whose
+// procedure did not set a status on it.
+const UNINITIALIZED_TABLE_STATUS_CODE = -128
+
 func (s Status) String() string {
-	if s == SUCCESS {
+	switch s {
+	case SUCCESS:
 		return "SUCCESS"
-	} else if s == USER_ABORT {
+	case USER_ABORT:
 		return "USER ABORT"
-	} else if s == GRACEFUL_FAILURE {
+	case GRACEFUL_FAILURE:
 		return "GRACEFUL FAILURE"
-	} else if s == UNEXPECTED_FAILURE {
+	case UNEXPECTED_FAILURE:
 		return "UNEXPECTED FAILURE"
-	} else if s == CONNECTION_LOST {
+	case CONNECTION_LOST:
 		return "CONNECTION LOST"
-	} else {
-		panic(fmt.Sprintf("Invalid status code: %d", int(s)))
+	case SERVER_UNAVAILABLE:
+		return "SERVER UNAVAILABLE"
+	case CONNECTION_TIMEOUT:
+		return "CONNECTION TIMEOUT"
+	case RESPONSE_UNKNOWN:
+		return "RESPONSE UNKNOWN"
+	case TXN_RESTART:
+		return "TXN RESTART"
+	case OPERATIONAL_FAILURE:
+		return "OPERATIONAL FAILURE"
 	}
-	return "unreachable"
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// ClientHandle returns the client handle of the call this Response
+// answers.
+func (rsp *Response) ClientHandle() int64 {
+	return rsp.clientData
 }
 
 func (rsp *Response) Status() Status {
@@ -154,31 +944,103 @@ func (rsp *Response) StatusString() string {
 	return rsp.statusString
 }
 
+// AppStatus returns the application status the procedure set with
+// setAppStatusCode, or UNINITIALIZED_APP_STATUS_CODE if it set none.
 func (rsp *Response) AppStatus() int {
 	return int(rsp.appStatus)
 }
 
+// AppStatusCode is AppStatus as the byte sent on the wire.
+func (rsp *Response) AppStatusCode() int8 {
+	return rsp.appStatus
+}
+
+// AppStatusString returns the application status string the procedure
+// set with setAppStatusString, or "" if it set none. Business logic
+// errors are often reported here.
 func (rsp *Response) AppStatusString() string {
 	return rsp.appStatusString
 }
 
+// ClusterRoundTripTime is the time in milliseconds the cluster spent
+// on the invocation, from receiving it to sending the response.
+func (rsp *Response) ClusterRoundTripTime() int32 {
+	return rsp.clusterRoundTrip
+}
+
+// ClusterLatency is ClusterRoundTripTime as an int. Protocol version 1
+// responses carry a single cluster timing field.
 func (rsp *Response) ClusterLatency() int {
-	return int(rsp.clusterLatency)
+	return int(rsp.clusterRoundTrip)
 }
 
 func (rsp *Response) ResultSets() []Table {
 	return rsp.tables
 }
 
+// Tables returns the response's result tables in order. It returns an
+// empty slice if the procedure returned none.
+func (rsp *Response) Tables() []*Table {
+	tables := make([]*Table, len(rsp.tables))
+	for idx := range rsp.tables {
+		tables[idx] = &rsp.tables[idx]
+	}
+	return tables
+}
+
+// Table returns result table offset. It panics if there is no such
+// table, as for a @Ping response, which has none; see Tables.
 func (rsp *Response) Table(offset int) *Table {
 	return &rsp.tables[offset]
 }
 
+// String summarizes rsp for logging: its status, latency and the
+// columns and row count of each table. For example,
+//
+//	SUCCESS, latency 3ms, 1 table: (ID, NAME) 2 rows
+//	GRACEFUL FAILURE: Constraint violation, latency 1ms, 0 tables
+func (rsp *Response) String() string {
+	var b strings.Builder
+	b.WriteString(rsp.Status().String())
+	if rsp.statusString != "" {
+		b.WriteString(": ")
+		b.WriteString(rsp.statusString)
+	}
+	fmt.Fprintf(&b, ", latency %dms", rsp.clusterRoundTrip)
+	if rsp.appStatus != UNINITIALIZED_APP_STATUS_CODE {
+		fmt.Fprintf(&b, ", app status %d", rsp.appStatus)
+		if rsp.appStatusString != "" {
+			b.WriteString(": ")
+			b.WriteString(rsp.appStatusString)
+		}
+	}
+	fmt.Fprintf(&b, ", %d %s", len(rsp.tables), plural(len(rsp.tables), "table"))
+	for idx := range rsp.tables {
+		table := &rsp.tables[idx]
+		if idx == 0 {
+			b.WriteString(": (")
+		} else {
+			b.WriteString("; (")
+		}
+		b.WriteString(strings.Join(table.columnNames, ", "))
+		fmt.Fprintf(&b, ") %d %s", table.rowCount, plural(int(table.rowCount), "row"))
+	}
+	return b.String()
+}
+
+// plural returns noun, made plural unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
 func (rsp *Response) GoString() string {
 	return fmt.Sprintf("Response: clientData:%v, status:%v, statusString:%v, "+
 		"clusterLatency: %v, appStatus: %v, appStatusString: %v\n",
 		rsp.clientData, rsp.status, rsp.statusString,
-		rsp.clusterLatency, rsp.appStatus, rsp.appStatusString)
+		rsp.clusterRoundTrip, rsp.appStatus, rsp.appStatusString)
 }
 
 // Table represents a single result set for a stored procedure invocation.
@@ -189,6 +1051,12 @@ type Table struct {
 	columnNames []string
 	rowCount    int32
 	rows        bytes.Buffer
+	rowData     []byte // every row, including those already read
+	row         []byte // current row, set by AdvanceRow
+	colOffsets  []int  // column offsets into row
+	validUTF8   bool   // reject STRING values that are not UTF-8
+	compression Compression
+	err         error // the malformed row that stopped AdvanceRow, if any
 }
 
 func (table *Table) GoString() string {
@@ -197,6 +1065,10 @@ func (table *Table) GoString() string {
 		table.rowCount)
 }
 
+// StatusCode returns the status the procedure set on the table, which
+// is read from the byte that precedes the table's columns, or
+// UNINITIALIZED_TABLE_STATUS_CODE. It is returned as an int so that it
+// compares directly with untyped constants.
 func (table *Table) StatusCode() int {
 	return int(table.statusCode)
 }
@@ -217,6 +1089,18 @@ func (table *Table) ColumnNames() []string {
 	return rv
 }
 
+// ColumnType returns the wire type code of column colIndex, as
+// declared in the table header. It panics if colIndex is out of range.
+func (table *Table) ColumnType(colIndex int) int8 {
+	return table.columnTypes[colIndex]
+}
+
+// ColumnName returns the name of column colIndex. It panics if
+// colIndex is out of range.
+func (table *Table) ColumnName(colIndex int) string {
+	return table.columnNames[colIndex]
+}
+
 // Rowcount returns the number of rows returned by the server for this table.
 func (table *Table) RowCount() int {
 	return int(table.rowCount)
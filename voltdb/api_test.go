@@ -2,11 +2,124 @@ package voltdb
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+// testServer speaks enough of the wire protocol to accept logins.
+// After each login it hands the connection to serve.
+type testServer struct {
+	listener net.Listener
+	serve    func(c net.Conn)
+}
+
+func newTestServer(t *testing.T, serve func(c net.Conn)) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	return newTestServerOn(listener, serve)
+}
+
+func newTestServerOn(listener net.Listener, serve func(c net.Conn)) *testServer {
+	server := &testServer{listener, serve}
+	go server.accept()
+	return server
+}
+
+func (server *testServer) accept() {
+	for {
+		c, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			if _, err := readMessage(c); err != nil {
+				return
+			}
+			var login bytes.Buffer
+			writeByte(&login, 0)             // authentication result
+			writeInt(&login, 1)              // host id
+			writeLong(&login, 2)             // connection id
+			writeLong(&login, 3)             // cluster start timestamp
+			writeInt(&login, 0x7F000001)     // leader address
+			writeString(&login, "testbuild") // build string
+			if writeMessage(c, login.Bytes()) != nil {
+				return
+			}
+			server.serve(c)
+		}()
+	}
+}
+
+func (server *testServer) addr() string {
+	return server.listener.Addr().String()
+}
+
+func (server *testServer) close() {
+	server.listener.Close()
+}
+
+// readTestInvocation reads a procedure invocation, returning the
+// procedure name, client handle and serialized parameters.
+func readTestInvocation(r io.Reader) (string, int64, *bytes.Buffer, error) {
+	payload, err := readMessage(r)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	buf := bytes.NewBuffer(payload)
+	proc, err := readString(buf)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	handle, err := readLong(buf)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return proc, handle, buf, nil
+}
+
+// writeTestResponse writes a response carrying status and the
+// serialized tables.
+func writeTestResponse(w io.Writer, handle int64, status int8, tables ...[]byte) error {
+	var rsp bytes.Buffer
+	writeLong(&rsp, handle)
+	writeByte(&rsp, 0) // fields present
+	writeByte(&rsp, status)
+	writeByte(&rsp, -128) // app status
+	writeInt(&rsp, 0)     // cluster round trip time
+	writeShort(&rsp, int16(len(tables)))
+	for _, table := range tables {
+		rsp.Write(table)
+	}
+	return writeMessage(w, rsp.Bytes())
+}
+
+// echoTable returns a single row, single column table holding val.
+func echoTable(val string) []byte {
+	var b bytes.Buffer
+	writeTestTable(&b, -128, []testColumn{{"ECHO", vt_STRING}},
+		[][]interface{}{{val}})
+	return b.Bytes()
+}
+
 func TestCallOnClosedConn(t *testing.T) {
-	conn := Conn{nil, nil}
+	var conn Conn
 	_, err := conn.Call("bad", 1, 2)
 	if err == nil {
 		t.Errorf("Expected error calling procedure on closed Conn")
@@ -21,12 +134,12 @@ func TestTableAccessors(t *testing.T) {
 	rowCount := 5
 	rows := bytes.NewBufferString("rowbuf")
 	table := Table{
-		int8(statusCode),
-		int16(columnCount),
-		columnTypes,
-		columnNames,
-		int32(rowCount),
-		*rows}
+		statusCode:  int8(statusCode),
+		columnCount: int16(columnCount),
+		columnTypes: columnTypes,
+		columnNames: columnNames,
+		rowCount:    int32(rowCount),
+		rows:        *rows}
 
 	if table.StatusCode() != statusCode {
 		t.Errorf("Bad StatusCode()")
@@ -46,3 +159,1321 @@ func TestTableAccessors(t *testing.T) {
 		t.Errorf("Bad RowCount()")
 	}
 }
+
+// echoServe answers each invocation with its procedure name.
+func echoServe(c net.Conn) {
+	for {
+		proc, handle, _, err := readTestInvocation(c)
+		if err != nil {
+			return
+		}
+		writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+	}
+}
+
+func TestCall(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	rsp, err := conn.Call("Echo", 1, "two")
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	if rsp.Status() != SUCCESS {
+		t.Errorf("Bad Status() %v", rsp.Status())
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Echo" {
+		t.Errorf("Expected Echo have %v", v)
+	}
+}
+
+func TestCallProcedureName(t *testing.T) {
+	calls := make(chan invocation, 3)
+	server := recordingServer(t, calls)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	longest := strings.Repeat("P", maxProcedureNameLength)
+	testVals := []struct {
+		procedure string
+		valid     bool
+	}{
+		{"", false},
+		{"Insert", true},
+		{longest, true},
+		{longest + "P", false},
+	}
+	for _, tv := range testVals {
+		_, err := conn.Call(tv.procedure)
+		if tv.valid && err != nil {
+			t.Errorf("Call of %d byte name produced error %v", len(tv.procedure), err)
+		} else if !tv.valid && err == nil {
+			t.Errorf("Expected error for %d byte name", len(tv.procedure))
+		}
+	}
+	if len(calls) != 2 {
+		t.Errorf("Bad invocations sent have %v wants 2", len(calls))
+	}
+}
+
+func TestCallAsync(t *testing.T) {
+	const calls = 5
+	// answer in reverse order once every call has arrived.
+	server := newTestServer(t, func(c net.Conn) {
+		var procs []string
+		var handles []int64
+		for i := 0; i < calls; i++ {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			procs = append(procs, proc)
+			handles = append(handles, handle)
+		}
+		for i := calls - 1; i >= 0; i-- {
+			writeTestResponse(c, handles[i], int8(SUCCESS), echoTable(procs[i]))
+		}
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(proc string) {
+			defer wg.Done()
+			future, err := conn.CallAsync(proc)
+			if err != nil {
+				t.Errorf("CallAsync produced error %v", err)
+				return
+			}
+			rsp, err := future.Get()
+			if err != nil {
+				t.Errorf("Get produced error %v", err)
+				return
+			}
+			table := rsp.Table(0)
+			table.AdvanceRow()
+			if v, _, _ := table.GetString(0); v != proc {
+				t.Errorf("Expected %v have %v", proc, v)
+			}
+		}(fmt.Sprintf("Proc%d", i))
+	}
+	wg.Wait()
+}
+
+func TestCallAsyncConnectionLost(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		readTestInvocation(c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	future, err := conn.CallAsync("Lost")
+	if err != nil {
+		t.Fatalf("CallAsync produced error %v", err)
+	}
+	if _, err := future.Get(); err == nil {
+		t.Errorf("Expected error when the connection is lost")
+	}
+}
+
+func TestClosePendingCalls(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	var futures []*Future
+	for i := 0; i < 5; i++ {
+		future, err := conn.CallAsync("Pending")
+		if err != nil {
+			t.Fatalf("CallAsync produced error %v", err)
+		}
+		futures = append(futures, future)
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close produced error %v", err)
+	}
+	for _, future := range futures {
+		if _, err := future.Get(); err != ErrClosed {
+			t.Errorf("Expected ErrClosed have %v", err)
+		}
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Second Close produced error %v", err)
+	}
+	if _, err := conn.CallAsync("Closed"); err != ErrClosed {
+		t.Errorf("Expected ErrClosed have %v", err)
+	}
+}
+
+func TestCloseConcurrentWithCall(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := conn.Call("Busy"); err != nil {
+					if err != ErrClosed {
+						t.Errorf("Expected ErrClosed have %v", err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	go conn.Close()
+	conn.Close()
+	wg.Wait()
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	// large invocations would interleave if writes were not serialized.
+	payload := string(bytes.Repeat([]byte("x"), 64*1024))
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			proc := fmt.Sprintf("Proc%d", i)
+			rsp, err := conn.Call(proc, payload)
+			if err != nil {
+				t.Errorf("Call produced error %v", err)
+				return
+			}
+			table := rsp.Table(0)
+			table.AdvanceRow()
+			if v, _, _ := table.GetString(0); v != proc {
+				t.Errorf("Expected %v have %v", proc, v)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCallContextTimeout(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := conn.CallContext(ctx, "Slow"); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded have %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CallContext returned after %v", elapsed)
+	}
+	conn.mu.Lock()
+	if len(conn.pending) != 0 {
+		t.Errorf("Expected abandoned call to be forgotten")
+	}
+	conn.mu.Unlock()
+}
+
+func TestCallContextCancelled(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.CallContext(ctx, "Cancelled"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled have %v", err)
+	}
+}
+
+// selfSignedCert returns a certificate for 127.0.0.1 and a pool
+// that trusts it.
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "voltdb test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestConnectTLS(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0",
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := newTestServerOn(listener, echoServe)
+	defer server.close()
+
+	conn, err := ConnectTLS(server.addr(), "user", "", &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatalf("ConnectTLS produced error %v", err)
+	}
+	defer conn.Close()
+	if _, ok := conn.netConn.(*tls.Conn); !ok {
+		t.Errorf("Expected a TLS connection")
+	}
+	rsp, err := conn.Call("Secure")
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Secure" {
+		t.Errorf("Expected Secure have %v", v)
+	}
+}
+
+// pipeConn joins the ends of two io.Pipes into one duplex transport.
+type pipeConn struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (p pipeConn) Close() error {
+	p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
+
+// newPipeConns returns the client and server ends of an in-memory
+// transport.
+func newPipeConns() (pipeConn, pipeConn) {
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+	return pipeConn{clientRead, clientWrite}, pipeConn{serverRead, serverWrite}
+}
+
+func TestNewConnOverPipe(t *testing.T) {
+	client, server := newPipeConns()
+	go func() {
+		defer server.Close()
+		if _, err := readMessage(server); err != nil {
+			return
+		}
+		if writeMessage(server, loginReply()) != nil {
+			return
+		}
+		for {
+			proc, handle, _, err := readTestInvocation(server)
+			if err != nil {
+				return
+			}
+			writeTestResponse(server, handle, int8(SUCCESS), echoTable(proc))
+		}
+	}()
+
+	conn, err := NewConn(client, "user", "")
+	if err != nil {
+		t.Fatalf("NewConn produced error %v", err)
+	}
+	conn.SetReadTimeout(time.Second)
+	rsp, err := conn.Call("Piped")
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Piped" {
+		t.Errorf("Expected Piped have %v", v)
+	}
+	if info := conn.ServerInfo(); info.BuildString != "testbuild" {
+		t.Errorf("Bad BuildString() have %v wants testbuild", info.BuildString)
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close produced error %v", err)
+	}
+}
+
+func TestNewConnReauthenticate(t *testing.T) {
+	client, server := newPipeConns()
+	go func() {
+		defer server.Close()
+		if _, err := readMessage(server); err != nil {
+			return
+		}
+		if writeMessage(server, loginReply()) != nil {
+			return
+		}
+		for {
+			proc, handle, _, err := readTestInvocation(server)
+			if err != nil {
+				return
+			}
+			writeTestResponse(server, handle, int8(SUCCESS), echoTable(proc))
+		}
+	}()
+
+	conn, err := NewConn(client, "user", "")
+	if err != nil {
+		t.Fatalf("NewConn produced error %v", err)
+	}
+	defer conn.Close()
+	loginMsg := conn.loginMsg
+	if err := conn.Reauthenticate("other", "new"); err == nil {
+		t.Errorf("Expected error reauthenticating a Conn made by NewConn")
+	}
+	if !bytes.Equal(conn.loginMsg, loginMsg) {
+		t.Errorf("Failed Reauthenticate replaced the login")
+	}
+	if _, err := conn.Call("Piped"); err != nil {
+		t.Errorf("Call after failed Reauthenticate produced error %v", err)
+	}
+}
+
+func TestNewConnLoginFailure(t *testing.T) {
+	client, server := newPipeConns()
+	go func() {
+		readMessage(server)
+		server.Close()
+	}()
+	if _, err := NewConn(client, "user", ""); err == nil {
+		t.Errorf("Expected NewConn to fail when the server hangs up")
+	}
+}
+
+func TestNewConnectionSHA1Fallback(t *testing.T) {
+	// the server predates SHA-256 and refuses logins that use it.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				msg, err := readMessage(c)
+				if err != nil {
+					return
+				}
+				if HashScheme(msg[0]) != SHA1 {
+					writeMessage(c, []byte{1})
+					return
+				}
+				if writeMessage(c, loginReply()) == nil {
+					echoServe(c)
+				}
+			}(c)
+		}
+	}()
+
+	conn, err := NewConnection("user", "passwd", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	expected, _ := serializeLoginMessage("user", "passwd", SHA1)
+	if !bytes.Equal(conn.loginMsg, expected.Bytes()) {
+		t.Errorf("Expected NewConnection to log in with SHA-1")
+	}
+	if _, err := conn.Call("Hello"); err != nil {
+		t.Errorf("Call produced error %v", err)
+	}
+
+	if _, err := NewConnectionWithScheme("user", "passwd", listener.Addr().String(), SHA256); err == nil {
+		t.Errorf("Expected NewConnectionWithScheme not to fall back to SHA-1")
+	}
+}
+
+func TestConnectWithDialer(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	laddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	dialer := &net.Dialer{Timeout: time.Second, LocalAddr: laddr}
+	conn, err := ConnectWithDialer(dialer, server.addr(), "user", "")
+	if err != nil {
+		t.Fatalf("ConnectWithDialer produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Call("Dialed"); err != nil {
+		t.Errorf("Call produced error %v", err)
+	}
+}
+
+func TestConnectWithDialerTimeout(t *testing.T) {
+	// A timeout that has passed before the dial begins fails it at
+	// once, whether or not the address would answer.
+	dialer := &net.Dialer{Timeout: time.Nanosecond}
+	start := time.Now()
+	_, err := ConnectWithDialer(dialer, "10.255.255.1:21212", "user", "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ConnectWithDialer took %v", elapsed)
+	}
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("Bad error have %v wants a timeout", err)
+	}
+}
+
+func TestConnectTLSUntrusted(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0",
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := newTestServerOn(listener, echoServe)
+	defer server.close()
+
+	if _, err := ConnectTLS(server.addr(), "user", "", &tls.Config{}); err == nil {
+		t.Errorf("Expected error connecting to untrusted server")
+	}
+}
+
+// a failed response with status and app status strings.
+var capturedFailureResponse = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2A, // client handle
+	0xA0, // fields present: status and app status strings
+	0xFE, // status GRACEFUL_FAILURE
+	0x00, 0x00, 0x00, 0x04, 'o', 'o', 'p', 's',
+	0x05, // app status
+	0x00, 0x00, 0x00, 0x03, 'a', 'p', 'p',
+	0x00, 0x00, 0x00, 0x07, // cluster round trip time
+	0x00, 0x00, // result count
+}
+
+func TestDeserializeFailureResponse(t *testing.T) {
+	rsp, err := deserializeCallResponse(bytes.NewBuffer(capturedFailureResponse))
+	if err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	if rsp.Status() != GRACEFUL_FAILURE {
+		t.Errorf("Bad Status() %v", rsp.Status())
+	}
+	if rsp.StatusString() != "oops" {
+		t.Errorf("Bad StatusString() %v", rsp.StatusString())
+	}
+	if rsp.AppStatus() != 5 {
+		t.Errorf("Bad AppStatus() %v", rsp.AppStatus())
+	}
+	if rsp.AppStatusString() != "app" {
+		t.Errorf("Bad AppStatusString() %v", rsp.AppStatusString())
+	}
+	if len(rsp.ResultSets()) != 0 {
+		t.Errorf("Expected no result sets, have %v", len(rsp.ResultSets()))
+	}
+}
+
+func TestDeserializeAppStatus(t *testing.T) {
+	testVals := []struct {
+		appSet   bool
+		appNull  bool
+		expected string
+	}{
+		{true, false, "Insufficient funds"},
+		{true, true, ""}, // present but NULL
+		{false, false, ""},
+	}
+	for _, tv := range testVals {
+		var b bytes.Buffer
+		writeLong(&b, 42)
+		if tv.appSet {
+			b.WriteByte(1 << 7) // fields present: app status string
+		} else {
+			b.WriteByte(0)
+		}
+		writeByte(&b, int8(USER_ABORT))
+		writeByte(&b, 3)
+		if tv.appSet && tv.appNull {
+			writeInt(&b, -1)
+		} else if tv.appSet {
+			writeString(&b, tv.expected)
+		}
+		writeInt(&b, 1)
+		writeShort(&b, 0)
+		rsp, err := decodeCallResponse(b.Bytes())
+		if err != nil {
+			t.Fatalf("decodeCallResponse produced error %v", err)
+		}
+		if rsp.AppStatusCode() != 3 || rsp.AppStatus() != 3 {
+			t.Errorf("Bad app status %v %v", rsp.AppStatusCode(), rsp.AppStatus())
+		}
+		if rsp.AppStatusString() != tv.expected {
+			t.Errorf("Bad AppStatusString() have %q wants %q", rsp.AppStatusString(), tv.expected)
+		}
+	}
+}
+
+func TestDeserializeSuccessResponse(t *testing.T) {
+	var b bytes.Buffer
+	writeLong(&b, 42)
+	writeByte(&b, 0)
+	writeByte(&b, int8(SUCCESS))
+	writeByte(&b, UNINITIALIZED_APP_STATUS_CODE)
+	writeInt(&b, 3)
+	writeShort(&b, 1)
+	b.Write(echoTable("ok"))
+	rsp, err := deserializeCallResponse(&b)
+	if err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	if rsp.Status() != SUCCESS || rsp.StatusString() != "" {
+		t.Errorf("Bad status %v %v", rsp.Status(), rsp.StatusString())
+	}
+	if rsp.AppStatus() != UNINITIALIZED_APP_STATUS_CODE || rsp.AppStatusString() != "" {
+		t.Errorf("Bad app status %v %v", rsp.AppStatus(), rsp.AppStatusString())
+	}
+	if len(rsp.ResultSets()) != 1 {
+		t.Errorf("Expected one result set, have %v", len(rsp.ResultSets()))
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	if GRACEFUL_FAILURE.String() != "GRACEFUL FAILURE" {
+		t.Errorf("Bad String() %v", GRACEFUL_FAILURE.String())
+	}
+	if Status(-100).String() != "Status(-100)" {
+		t.Errorf("Bad String() for unknown status %v", Status(-100).String())
+	}
+}
+
+func TestClusterRoundTripTime(t *testing.T) {
+	rsp, err := deserializeCallResponse(bytes.NewBuffer(capturedFailureResponse))
+	if err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	if rsp.ClusterRoundTripTime() != 7 || rsp.ClusterLatency() != 7 {
+		t.Errorf("Bad round trip time %v, %v", rsp.ClusterRoundTripTime(), rsp.ClusterLatency())
+	}
+
+	// without the optional strings the field follows the app status.
+	var b bytes.Buffer
+	writeLong(&b, 1)
+	writeByte(&b, 0)
+	writeByte(&b, int8(SUCCESS))
+	writeByte(&b, 0)
+	writeInt(&b, 0x01020304)
+	writeShort(&b, 0)
+	if rsp, err = deserializeCallResponse(&b); err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	if rsp.ClusterRoundTripTime() != 0x01020304 {
+		t.Errorf("Bad round trip time %x", rsp.ClusterRoundTripTime())
+	}
+}
+
+func TestTables(t *testing.T) {
+	var first, second bytes.Buffer
+	writeTestTable(&first, -128, []testColumn{{"ID", vt_INT}},
+		[][]interface{}{{int32(1)}, {int32(2)}})
+	writeTestTable(&second, -128, []testColumn{{"NAME", vt_STRING}, {"SCORE", vt_FLOAT}},
+		[][]interface{}{{"one", 1.5}})
+	var b bytes.Buffer
+	writeTestResponse(&b, 1, int8(SUCCESS), first.Bytes(), second.Bytes())
+	payload, err := readMessage(&b)
+	if err != nil {
+		t.Fatalf("readMessage produced error %v", err)
+	}
+	rsp, err := deserializeCallResponse(bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	tables := rsp.Tables()
+	if len(tables) != 2 {
+		t.Fatalf("Expected 2 tables, have %v", len(tables))
+	}
+	if tables[0].ColumnCount() != 1 || tables[0].RowCount() != 2 {
+		t.Errorf("Bad first table %v columns %v rows",
+			tables[0].ColumnCount(), tables[0].RowCount())
+	}
+	if tables[1].ColumnCount() != 2 || tables[1].RowCount() != 1 {
+		t.Errorf("Bad second table %v columns %v rows",
+			tables[1].ColumnCount(), tables[1].RowCount())
+	}
+	tables[1].AdvanceRow()
+	if name, _, _ := tables[1].GetString(0); name != "one" {
+		t.Errorf("Expected one have %v", name)
+	}
+
+	b.Reset()
+	writeTestResponse(&b, 2, int8(SUCCESS))
+	payload, _ = readMessage(&b)
+	if rsp, err = deserializeCallResponse(bytes.NewBuffer(payload)); err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	if tables := rsp.Tables(); tables == nil || len(tables) != 0 {
+		t.Errorf("Expected empty non-nil tables, have %#v", tables)
+	}
+}
+
+func TestCallSmallIntegers(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			_, handle, params, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			// echo a TINYINT and a SMALLINT parameter as a row.
+			readShort(params)
+			var row []interface{}
+			var cols []testColumn
+			for idx := 0; idx < 2; idx++ {
+				vt, _ := readByte(params)
+				var val interface{}
+				if vt == vt_TINYINT {
+					val, _ = readByte(params)
+				} else {
+					val, _ = readShort(params)
+				}
+				cols = append(cols, testColumn{"C", vt})
+				row = append(row, val)
+			}
+			var table bytes.Buffer
+			writeTestTable(&table, -128, cols, [][]interface{}{row})
+			writeTestResponse(c, handle, int8(SUCCESS), table.Bytes())
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	rsp, err := conn.Call("Echo", int8(-7), int16(1234))
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, isNull, err := table.GetByte(0); v != -7 || isNull || err != nil {
+		t.Errorf("Bad GetByte %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetShort(1); v != 1234 || isNull || err != nil {
+		t.Errorf("Bad GetShort %v, %v, %v", v, isNull, err)
+	}
+	var b int8
+	var s int16
+	if err := table.Scan(&b, &s); err != nil || b != -7 || s != 1234 {
+		t.Errorf("Bad Scan %v, %v, %v", b, s, err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Ping(); err != nil {
+		t.Errorf("Ping produced error %v", err)
+	}
+	if !conn.TestConnection() {
+		t.Errorf("Expected TestConnection to succeed")
+	}
+
+	conn.netConn.Close()
+	for !conn.failed() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := conn.Ping(); err == nil {
+		t.Errorf("Expected Ping error on a closed socket")
+	}
+}
+
+// capturedPingResponse is a @Ping response: SUCCESS and no tables.
+var capturedPingResponse = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, // client handle
+	0x00,                   // fields present
+	0x01,                   // status SUCCESS
+	0x80,                   // app status
+	0x00, 0x00, 0x00, 0x00, // cluster round trip time
+	0x00, 0x00, // result count
+}
+
+func TestDeserializePingResponse(t *testing.T) {
+	rsp, err := decodeCallResponse(capturedPingResponse)
+	if err != nil {
+		t.Fatalf("decodeCallResponse produced error %v", err)
+	}
+	if rsp.Status() != SUCCESS || rsp.ClientHandle() != 7 {
+		t.Errorf("Bad response %v", rsp)
+	}
+	if tables := rsp.Tables(); tables == nil || len(tables) != 0 {
+		t.Errorf("Expected empty Tables() have %#v", tables)
+	}
+	if len(rsp.ResultSets()) != 0 {
+		t.Errorf("Expected no result sets, have %v", len(rsp.ResultSets()))
+	}
+	if s := rsp.String(); s != "SUCCESS, latency 0ms, 0 tables" {
+		t.Errorf("Bad String() %v", s)
+	}
+}
+
+func TestPingFailedStatus(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			_, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			writeTestResponse(c, handle, int8(SERVER_UNAVAILABLE))
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Ping(); err == nil {
+		t.Errorf("Expected Ping error for a failed status")
+	}
+}
+
+func TestConnValidateUTF8(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	bad := string([]byte{0xc3, 0x28})
+	rsp, err := conn.Call(bad)
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	rsp.Table(0).AdvanceRow()
+	if _, _, err := rsp.Table(0).GetString(0); err != nil {
+		t.Errorf("Expected lenient decoding by default, have %v", err)
+	}
+
+	conn.SetValidateUTF8(true)
+	if rsp, err = conn.Call(bad); err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	rsp.Table(0).AdvanceRow()
+	if _, _, err := rsp.Table(0).GetString(0); err == nil {
+		t.Errorf("Expected error for invalid UTF-8")
+	}
+}
+
+func TestCallBatch(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		// answer only once all three calls have arrived, last first.
+		var procs []string
+		var handles []int64
+		for len(procs) < 3 {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			procs = append(procs, proc)
+			handles = append(handles, handle)
+		}
+		for idx := len(procs) - 1; idx >= 0; idx-- {
+			writeTestResponse(c, handles[idx], int8(SUCCESS), echoTable(procs[idx]))
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	calls := []Invocation{{"First", nil, false}, {"Second", []interface{}{1}, false}, {"Third", []interface{}{"a", 2.5}, false}}
+	rsps, err := conn.CallBatch(calls)
+	if err != nil {
+		t.Fatalf("CallBatch produced error %v", err)
+	}
+	for idx, rsp := range rsps {
+		table := rsp.Table(0)
+		table.AdvanceRow()
+		if v, _, _ := table.GetString(0); v != calls[idx].Procedure {
+			t.Errorf("Response %d has %v wants %v", idx, v, calls[idx].Procedure)
+		}
+	}
+}
+
+func TestClientHandle(t *testing.T) {
+	handles := make(chan int64, 2)
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			handles <- handle
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		future, err := conn.CallAsync("Correlate")
+		if err != nil {
+			t.Fatalf("CallAsync produced error %v", err)
+		}
+		rsp, err := future.Get()
+		if err != nil {
+			t.Fatalf("Get produced error %v", err)
+		}
+		wire := <-handles
+		if future.Handle() != wire || rsp.ClientHandle() != wire {
+			t.Errorf("Future has handle %v, response %v, wire %v",
+				future.Handle(), rsp.ClientHandle(), wire)
+		}
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	calls := make(chan invocation, 1)
+	server := recordingServer(t, calls)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	d, _ := new(big.Rat).SetString("0.0000000000025")
+	if _, err := conn.Call("Pay", d); err == nil {
+		t.Errorf("Expected error sending a decimal with 13 fractional digits")
+	}
+
+	conn.SetDecimalRounding(RoundHalfEven)
+	if _, err := conn.Call("Pay", d); err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	var expected bytes.Buffer
+	writeParameterSet(&expected, []interface{}{big.NewRat(2, 1000000000000)})
+	if call := <-calls; !bytes.Equal(call.params, expected.Bytes()) {
+		t.Errorf("Bad rounded parameter have %v wants %v", call.params, expected.Bytes())
+	}
+	if d.FloatString(13) != "0.0000000000025" {
+		t.Errorf("Expected the argument to be left alone, have %v", d)
+	}
+}
+
+func TestExec(t *testing.T) {
+	modified := func(rows ...interface{}) *MockResponse {
+		table := &MockTable{Columns: []string{"modified_tuples"}}
+		for _, row := range rows {
+			table.Rows = append(table.Rows, []interface{}{row})
+		}
+		return &MockResponse{Tables: []*MockTable{table}}
+	}
+	server := newMockServer(t)
+	defer server.Close()
+	server.Respond("Insert", modified(int64(3)))
+	server.Respond("WrongType", modified(int32(3)))
+	server.Respond("TwoRows", modified(int64(1), int64(2)))
+	server.Respond("Select", echoResponse("row"))
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if count, err := conn.Exec("Insert", 1); count != 3 || err != nil {
+		t.Errorf("Bad Exec have %v, %v wants 3", count, err)
+	}
+	for _, proc := range []string{"WrongType", "TwoRows", "Select"} {
+		if _, err := conn.Exec(proc); err == nil {
+			t.Errorf("Expected Exec(%v) to fail", proc)
+		}
+	}
+}
+
+func TestResponseString(t *testing.T) {
+	cols := []testColumn{{"ID", vt_INT}, {"NAME", vt_STRING}}
+	table := newTestTable(t, cols, [][]interface{}{{int32(1), "a"}, {int32(2), "b"}})
+	count := newTestTable(t, []testColumn{{"modified_tuples", vt_LONG}}, [][]interface{}{{int64(1)}})
+	rsp := &Response{status: int8(SUCCESS), appStatus: UNINITIALIZED_APP_STATUS_CODE,
+		clusterRoundTrip: 3, tables: []Table{*table, *count}}
+	expected := "SUCCESS, latency 3ms, 2 tables: (ID, NAME) 2 rows; (modified_tuples) 1 row"
+	if s := rsp.String(); s != expected {
+		t.Errorf("Bad String() have %q wants %q", s, expected)
+	}
+
+	rsp = &Response{status: int8(GRACEFUL_FAILURE), statusString: "Constraint violation",
+		appStatus: 5, appStatusString: "retry later", clusterRoundTrip: 1}
+	expected = "GRACEFUL FAILURE: Constraint violation, latency 1ms, app status 5: retry later, 0 tables"
+	if s := rsp.String(); s != expected {
+		t.Errorf("Bad String() have %q wants %q", s, expected)
+	}
+}
+
+func TestCallWithCallback(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	const calls = 10
+	results := make(chan string, calls)
+	for i := 0; i < calls; i++ {
+		err := conn.CallWithCallback(fmt.Sprintf("Proc%d", i), nil, func(rsp *Response, err error) {
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			table := rsp.Table(0)
+			table.AdvanceRow()
+			v, _, _ := table.GetString(0)
+			results <- v
+		})
+		if err != nil {
+			t.Fatalf("CallWithCallback produced error %v", err)
+		}
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < calls; i++ {
+		seen[<-results] = true
+	}
+	for i := 0; i < calls; i++ {
+		if proc := fmt.Sprintf("Proc%d", i); !seen[proc] {
+			t.Errorf("Expected a callback with %v, have %v", proc, seen)
+		}
+	}
+	if err := conn.CallWithCallback("Proc", nil, nil); err == nil {
+		t.Errorf("Expected error for a nil callback")
+	}
+}
+
+func TestCallWithCallbackClosed(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	result := make(chan error, 1)
+	if err := conn.CallWithCallback("Never", nil, func(rsp *Response, err error) {
+		result <- err
+	}); err != nil {
+		t.Fatalf("CallWithCallback produced error %v", err)
+	}
+	conn.Close()
+	if err := <-result; err != ErrClosed {
+		t.Errorf("Expected ErrClosed have %v", err)
+	}
+}
+
+// gatedServer answers one invocation of procs for each value
+// received on gate.
+func gatedServer(t *testing.T, gate <-chan struct{}, procs ...string) *MockServer {
+	server := newMockServer(t)
+	handleEcho(server, func() { <-gate }, procs...)
+	return server
+}
+
+func TestMaxOutstanding(t *testing.T) {
+	gate := make(chan struct{})
+	server := gatedServer(t, gate, "Queued", "Blocked")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetMaxOutstanding(2)
+	var futures []*Future
+	for i := 0; i < 2; i++ {
+		future, err := conn.CallAsync("Queued")
+		if err != nil {
+			t.Fatalf("CallAsync produced error %v", err)
+		}
+		futures = append(futures, future)
+	}
+
+	sent := make(chan *Future)
+	go func() {
+		future, err := conn.CallAsync("Blocked")
+		if err != nil {
+			t.Errorf("CallAsync produced error %v", err)
+		}
+		sent <- future
+	}()
+	select {
+	case <-sent:
+		t.Fatalf("Expected CallAsync to block with the window full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := conn.CallContext(ctx, "Waiting"); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded waiting for a slot, have %v", err)
+	}
+
+	gate <- struct{}{}
+	if _, err := futures[0].Get(); err != nil {
+		t.Fatalf("Get produced error %v", err)
+	}
+	blocked := <-sent
+	close(gate)
+	for _, future := range []*Future{futures[1], blocked} {
+		if _, err := future.Get(); err != nil {
+			t.Errorf("Get produced error %v", err)
+		}
+	}
+}
+
+func TestReauthenticate(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	defer server.Close()
+	server.Respond("Hello", &MockResponse{})
+
+	conn, err := NewConnection("user", "old", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Call("Hello"); err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	netConn := conn.netConn
+	if err := conn.Reauthenticate("other", "new"); err != nil {
+		t.Fatalf("Reauthenticate produced error %v", err)
+	}
+	if conn.netConn == netConn {
+		t.Errorf("Reauthenticate did not log in on a new socket")
+	}
+	expected, _ := serializeLoginMessage("other", "new", SHA256)
+	if !bytes.Equal(conn.loginMsg, expected.Bytes()) {
+		t.Errorf("Reconnects would not replay the new login")
+	}
+	for i := 0; i < 2; i++ {
+		if rsp, err := conn.Call("Hello"); err != nil || rsp.Status() != SUCCESS {
+			t.Errorf("Call after Reauthenticate produced %v, %v", rsp, err)
+		}
+	}
+}
+
+func TestReauthenticateRejected(t *testing.T) {
+	// the first login is accepted and later ones rejected.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for logins := 0; ; logins++ {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn, accept bool) {
+				defer c.Close()
+				if _, err := readMessage(c); err != nil {
+					return
+				}
+				if !accept {
+					writeMessage(c, []byte{1}) // authentication failed
+					return
+				}
+				writeMessage(c, mockLoginResponse())
+				echoServe(c)
+			}(c, logins == 0)
+		}
+	}()
+
+	conn, err := NewConnection("user", "", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	loginMsg, netConn := conn.loginMsg, conn.netConn
+	if err := conn.Reauthenticate("user", "wrong"); err == nil {
+		t.Errorf("Expected error for a rejected login")
+	}
+	if conn.netConn != netConn {
+		t.Errorf("Rejected login replaced the socket")
+	}
+	if !bytes.Equal(conn.loginMsg, loginMsg) {
+		t.Errorf("Rejected login replaced the login to replay")
+	}
+	if _, err := conn.Call("Echo"); err != nil {
+		t.Errorf("Call after rejected login produced error %v", err)
+	}
+}
+
+func TestReauthenticatePending(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallAsync("Slow"); err != nil {
+		t.Fatalf("CallAsync produced error %v", err)
+	}
+	if err := conn.Reauthenticate("user", ""); err == nil {
+		t.Errorf("Expected error with a call pending")
+	}
+	conn.Close()
+	if err := conn.Reauthenticate("user", ""); err != ErrClosed {
+		t.Errorf("Bad error have %v wants %v", err, ErrClosed)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	server := delayServer(t, 20*time.Millisecond, "Async", "Callback")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Drain(); err != nil {
+		t.Errorf("Drain of an idle Conn produced error %v", err)
+	}
+
+	var futures []*Future
+	for i := 0; i < 5; i++ {
+		future, err := conn.CallAsync("Async")
+		if err != nil {
+			t.Fatalf("CallAsync produced error %v", err)
+		}
+		futures = append(futures, future)
+	}
+	var called int32
+	err = conn.CallWithCallback("Callback", nil, func(*Response, error) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&called, 1)
+	})
+	if err != nil {
+		t.Fatalf("CallWithCallback produced error %v", err)
+	}
+	if err := conn.Drain(); err != nil {
+		t.Fatalf("Drain produced error %v", err)
+	}
+	for idx, future := range futures {
+		select {
+		case <-future.done:
+		default:
+			t.Errorf("Future %d unresolved after Drain", idx)
+		}
+	}
+	if atomic.LoadInt32(&called) != 1 {
+		t.Errorf("Drain returned before the callback did")
+	}
+}
+
+func TestDrainConnectionLost(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		readTestInvocation(c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallAsync("Lost"); err != nil {
+		t.Fatalf("CallAsync produced error %v", err)
+	}
+	if err := conn.Drain(); err == nil {
+		t.Errorf("Expected error draining a lost connection")
+	}
+	conn.Close()
+	if err := conn.Drain(); err != ErrClosed {
+		t.Errorf("Bad error have %v wants %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,140 @@
+package voltdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compression.go shrinks VARBINARY payloads on slow links. VoltDB's
+// wire protocol has no compression of its own, so this is a client
+// convention: a Conn with compression enabled stores the VARBINARY
+// parameters it sends compressed and expands compressed VARBINARY
+// columns it reads. Each value it stores starts with a flag byte
+// saying whether the rest is compressed, so no value is taken for
+// compressed data by its contents. The server sees, and stores, opaque
+// bytes, so all clients sharing such data must opt in.
+
+// Compression selects how VARBINARY payloads are compressed.
+type Compression int
+
+const (
+	NoCompression Compression = iota
+	// Gzip compresses payloads with gzip. Snappy is not offered, as
+	// the package depends only on the standard library.
+	Gzip
+)
+
+// ConnectOptions configures a Conn made by ConnectWithOptions.
+type ConnectOptions struct {
+	Compression Compression
+}
+
+// ConnectWithOptions is NewConnection configured by opts.
+func ConnectWithOptions(hostAndPort string, user string, passwd string, opts ConnectOptions) (*Conn, error) {
+	if opts.Compression != NoCompression && opts.Compression != Gzip {
+		return nil, fmt.Errorf("Unknown compression %d.", opts.Compression)
+	}
+	conn, err := NewConnection(user, passwd, hostAndPort)
+	if err != nil {
+		return nil, err
+	}
+	conn.mu.Lock()
+	conn.compression = opts.Compression
+	conn.mu.Unlock()
+	return conn, nil
+}
+
+// Flag bytes that start each stored value.
+const (
+	storedRaw  = 0 // the rest is the value, which gzip did not shrink
+	storedGzip = 1 // the rest is the gzip compressed value
+)
+
+// compressParams returns params with each []byte, and each element of
+// each [][]byte, compressed. NULLs are left as they are. params itself
+// is left unchanged.
+func compressParams(params []interface{}) ([]interface{}, error) {
+	var compressed []interface{}
+	for idx, param := range params {
+		var val interface{}
+		var err error
+		switch x := param.(type) {
+		case []byte:
+			if x == nil {
+				continue
+			}
+			val, err = compress(x)
+		case [][]byte:
+			arr := make([][]byte, len(x))
+			for i, bs := range x {
+				if bs != nil {
+					if arr[i], err = compress(bs); err != nil {
+						break
+					}
+				}
+			}
+			val = arr
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Parameter %d: %v", idx, err)
+		}
+		if compressed == nil {
+			compressed = append([]interface{}(nil), params...)
+		}
+		compressed[idx] = val
+	}
+	if compressed == nil {
+		return params, nil
+	}
+	return compressed, nil
+}
+
+// compress returns data as stored: flagged as compressed, or as raw
+// if gzip does not shrink it.
+func compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte(storedGzip)
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if b.Len() > len(data)+1 {
+		return append([]byte{storedRaw}, data...), nil
+	}
+	return b.Bytes(), nil
+}
+
+// decompress returns the value stored as data by compress. Data that
+// does not start with a known flag, or whose gzip stream is corrupt,
+// is an error.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("Compressed value has no flag byte.")
+	}
+	switch data[0] {
+	case storedRaw:
+		return data[1:], nil
+	case storedGzip:
+	default:
+		return nil, fmt.Errorf("Unknown compression flag %d.", data[0])
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("Corrupt compressed value: %v", err)
+	}
+	expanded, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Corrupt compressed value: %v", err)
+	}
+	if len(expanded) > maxMessageSize {
+		return nil, fmt.Errorf("Compressed value expands beyond %d bytes.", maxMessageSize)
+	}
+	return expanded, nil
+}
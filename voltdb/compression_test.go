@@ -0,0 +1,120 @@
+package voltdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("volt"), 1000)
+	compressed, err := compress(data)
+	if err != nil {
+		t.Fatalf("compress produced error %v", err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("Expected %d bytes to compress, have %d", len(data), len(compressed))
+	}
+	expanded, err := decompress(compressed)
+	if err != nil || !bytes.Equal(expanded, data) {
+		t.Errorf("Bad decompress %v, %v", len(expanded), err)
+	}
+	// a value that looks like gzip data is stored raw, as it does
+	// not shrink, and still read back unchanged.
+	gzipLike := []byte{0x1f, 0x8b, 0x00}
+	stored, err := compress(gzipLike)
+	if err != nil || stored[0] != storedRaw {
+		t.Errorf("Expected a short value stored raw, have %x, %v", stored, err)
+	}
+	if expanded, err := decompress(stored); err != nil || !bytes.Equal(expanded, gzipLike) {
+		t.Errorf("Bad decompress have %x, %v wants %x", expanded, err, gzipLike)
+	}
+	stored, _ = compress([]byte{})
+	if expanded, err := decompress(stored); err != nil || len(expanded) != 0 {
+		t.Errorf("Expected the empty value stored raw, have %x, %v", expanded, err)
+	}
+}
+
+func TestDecompressErrors(t *testing.T) {
+	compressed, _ := compress(bytes.Repeat([]byte("volt"), 1000))
+	corrupt := append([]byte(nil), compressed[:len(compressed)-8]...)
+	testVals := map[string][]byte{
+		"no flag":      {},
+		"unknown flag": {0x1f, 0x8b, 0x00},
+		"bad header":   {storedGzip, 0x1f, 0x8b},
+		"truncated":    corrupt,
+	}
+	for name, data := range testVals {
+		if _, err := decompress(data); err == nil {
+			t.Errorf("Expected error for %v data", name)
+		}
+	}
+}
+
+func TestCompressParams(t *testing.T) {
+	data := bytes.Repeat([]byte("volt"), 1000)
+	params := []interface{}{data, [][]byte{data, nil}, "text", []byte(nil)}
+	compressed, err := compressParams(params)
+	if err != nil {
+		t.Fatalf("compressParams produced error %v", err)
+	}
+	if bs := compressed[0].([]byte); bs[0] != storedGzip {
+		t.Errorf("Expected the []byte compressed, have flag %d", bs[0])
+	}
+	arr := compressed[1].([][]byte)
+	if arr[0][0] != storedGzip || arr[1] != nil {
+		t.Errorf("Expected the [][]byte elements compressed, have flag %d and %v", arr[0][0], arr[1])
+	}
+	if compressed[2] != "text" || compressed[3].([]byte) != nil {
+		t.Errorf("Expected other params unchanged, have %v, %v", compressed[2], compressed[3])
+	}
+	if !bytes.Equal(params[0].([]byte), data) || !bytes.Equal(params[1].([][]byte)[0], data) {
+		t.Errorf("compressParams changed params")
+	}
+}
+
+// blobServer answers each invocation of Store with a VARBINARY table
+// holding its single VARBINARY parameter, as stored.
+func blobServer(t *testing.T, stored chan<- []byte) *MockServer {
+	server := newMockServer(t)
+	server.Handle("Store", func(params []interface{}) *MockResponse {
+		blob, _ := params[0].([]byte)
+		stored <- blob
+		return &MockResponse{Tables: []*MockTable{{
+			Columns: []string{"BLOB"},
+			Rows:    [][]interface{}{{blob}},
+		}}}
+	})
+	return server
+}
+
+func TestConnectWithCompression(t *testing.T) {
+	stored := make(chan []byte, 1)
+	server := blobServer(t, stored)
+	defer server.Close()
+
+	conn, err := ConnectWithOptions(server.Addr(), "user", "", ConnectOptions{Compression: Gzip})
+	if err != nil {
+		t.Fatalf("ConnectWithOptions produced error %v", err)
+	}
+	defer conn.Close()
+	data := bytes.Repeat([]byte("volt"), 1000)
+	rsp, err := conn.Call("Store", data)
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	if blob := <-stored; len(blob) >= len(data) {
+		t.Errorf("Expected the parameter to be sent compressed, have %d bytes", len(blob))
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	var blob []byte
+	if err := table.Scan(&blob); err != nil || !bytes.Equal(blob, data) {
+		t.Errorf("Expected the column to be decompressed, have %d bytes, %v", len(blob), err)
+	}
+}
+
+func TestConnectWithUnknownCompression(t *testing.T) {
+	if _, err := ConnectWithOptions("127.0.0.1:0", "user", "", ConnectOptions{Compression: 7}); err == nil {
+		t.Errorf("Expected error for an unknown compression")
+	}
+}
@@ -0,0 +1,64 @@
+package voltdb
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// timestampFormat is RFC 3339 with the microsecond precision of a
+// TIMESTAMP.
+const timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// WriteCSV writes a header line of column names to w, followed by
+// one line per row of the table not yet read by AdvanceRow; unlike
+// SumColumn, it leaves out rows already read. NULL columns are
+// written as empty fields, TIMESTAMPs in RFC 3339 with microseconds,
+// VARBINARY in hex and geospatial values as well-known text. Like
+// Rows, it does not advance the table.
+func (table *Table) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(table.columnNames); err != nil {
+		return err
+	}
+	record := make([]string, len(table.columnTypes))
+	rows := table.unreadRows()
+	for rows.AdvanceRow() {
+		for idx := range rows.columnTypes {
+			val, err := rows.value(idx)
+			if err != nil {
+				return err
+			}
+			record[idx] = csvField(val)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvField formats a column value returned by Table.value.
+func csvField(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(timestampFormat)
+	case *big.Rat:
+		return v.FloatString(decimalScale)
+	case []byte:
+		return hex.EncodeToString(v)
+	}
+	return fmt.Sprint(val)
+}
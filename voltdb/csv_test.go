@@ -0,0 +1,68 @@
+package voltdb
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestWriteCSV(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 123456000, time.UTC)
+	amount, _ := new(big.Rat).SetString("-12.5")
+	cols := []testColumn{{"ID", vt_INT}, {"NAME", vt_STRING}, {"AT", vt_TIMESTAMP},
+		{"AMOUNT", vt_DECIMAL}, {"RAW", vt_VARBIN}}
+	table := newTestTable(t, cols, [][]interface{}{
+		{int32(1), "Smith, John", ts, amount, []byte{0xCA, 0xFE}},
+		{int32(2), "two\nlines \"quoted\"", nil, nil, nil},
+		{nil, nil, nil, nil, nil},
+	})
+	var b bytes.Buffer
+	if err := table.WriteCSV(&b); err != nil {
+		t.Fatalf("WriteCSV produced error %v", err)
+	}
+	expected := "ID,NAME,AT,AMOUNT,RAW\n" +
+		"1,\"Smith, John\",2012-05-17T10:30:15.123456Z,-12.500000000000,cafe\n" +
+		"2,\"two\nlines \"\"quoted\"\"\",,,\n" +
+		",,,,\n"
+	if b.String() != expected {
+		t.Errorf("Bad CSV have %q wants %q", b.String(), expected)
+	}
+}
+
+func TestWriteCSVGeography(t *testing.T) {
+	ring := []GeographyPoint{{0, 0}, {1, 0}, {0, 1}, {0, 0}}
+	cols := []testColumn{{"P", vt_POINT}, {"G", vt_GEOGRAPHY}}
+	table := newTestTable(t, cols, [][]interface{}{
+		{GeographyPoint{-71.5, 42.25}, &Geography{Rings: [][]GeographyPoint{ring}}},
+	})
+	var b bytes.Buffer
+	if err := table.WriteCSV(&b); err != nil {
+		t.Fatalf("WriteCSV produced error %v", err)
+	}
+	if b.Len() == 0 || !bytes.HasPrefix(b.Bytes(), []byte("P,G\nPOINT (-71.5 42.25),\"POLYGON ((")) {
+		t.Errorf("Bad CSV %q", b.String())
+	}
+}
+
+func TestWriteCSVLeavesCursor(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"NAME", vt_STRING}},
+		[][]interface{}{{"a"}, {"b"}, {"c"}})
+	table.AdvanceRow()
+	var b bytes.Buffer
+	if err := table.WriteCSV(&b); err != nil {
+		t.Fatalf("WriteCSV produced error %v", err)
+	}
+	if b.String() != "NAME\nb\nc\n" {
+		t.Errorf("Bad CSV of unread rows have %q wants %q", b.String(), "NAME\nb\nc\n")
+	}
+	if v, _, _ := table.GetString(0); v != "a" {
+		t.Errorf("WriteCSV moved the current row to %v", v)
+	}
+	if !table.AdvanceRow() {
+		t.Fatalf("WriteCSV consumed the table")
+	}
+	if v, _, _ := table.GetString(0); v != "b" {
+		t.Errorf("WriteCSV consumed rows, next row is %v", v)
+	}
+}
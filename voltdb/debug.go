@@ -0,0 +1,32 @@
+package voltdb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// SetDebugWriter makes the Conn write a hex dump, with offsets and
+// ASCII, of every frame it sends or receives from now on to w, each
+// labeled with its direction and length. Login messages, which carry
+// the password hash, are not dumped. A nil w, the default, turns
+// dumping off.
+func (conn *Conn) SetDebugWriter(w io.Writer) {
+	conn.mu.Lock()
+	conn.debug = w
+	conn.mu.Unlock()
+}
+
+// dumpFrame writes a hex dump of frame to the debug writer, if set.
+func (conn *Conn) dumpFrame(direction string, frame []byte) {
+	conn.mu.Lock()
+	w := conn.debug
+	conn.mu.Unlock()
+	if w == nil {
+		return
+	}
+	dump := fmt.Sprintf("%s %d bytes\n%s", direction, len(frame), hex.Dump(frame))
+	conn.debugMu.Lock()
+	io.WriteString(w, dump)
+	conn.debugMu.Unlock()
+}
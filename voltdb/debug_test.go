@@ -0,0 +1,64 @@
+package voltdb
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.String()
+}
+
+func TestSetDebugWriter(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	var dump syncBuffer
+	conn.SetDebugWriter(&dump)
+	if _, err := conn.Call("Hello"); err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	conn.SetDebugWriter(nil)
+	if _, err := conn.Call("Unseen"); err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+
+	out := dump.String()
+	sent := strings.Index(out, "sent ")
+	received := strings.Index(out, "received ")
+	if sent < 0 || received < sent {
+		t.Fatalf("Expected a sent and then a received frame, have\n%v", out)
+	}
+	// the procedure name follows the 5 byte header and its length.
+	sentDump := out[sent:received]
+	if !strings.Contains(sentDump, "00 00 00  05 48 65 6c 6c 6f") {
+		t.Errorf("Sent frame does not hold the procedure name:\n%v", sentDump)
+	}
+	if !strings.Contains(sentDump, "|.........Hello") {
+		t.Errorf("Sent frame does not show Hello as ASCII:\n%v", sentDump)
+	}
+	if strings.Contains(out, "Unseen") {
+		t.Errorf("Dump continued after SetDebugWriter(nil)")
+	}
+}
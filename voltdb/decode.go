@@ -74,3 +74,87 @@ func (table *Table) next(v interface{}) error {
 
 	return nil
 }
+
+// Decode populates the struct pointed to by v with the current row.
+// A field tagged `voltdb:"NAME"` is set from the column NAME; an
+// untagged exported field from the column matching its name. Names
+// match case-insensitively. Columns without a field are ignored and
+// fields without a column are left alone. A NULL column sets a
+// pointer field to nil and any other field to its zero value. If
+// AdvanceRow stopped at a malformed row, Decode returns its error.
+func (table *Table) Decode(v interface{}) error {
+	if table.err != nil {
+		return table.err
+	}
+	if table.row == nil {
+		return fmt.Errorf("No current row. Call AdvanceRow first.")
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Must supply a struct pointer.")
+	}
+	structVal := rv.Elem()
+	typeOfT := structVal.Type()
+	for idx := 0; idx < typeOfT.NumField(); idx++ {
+		field := typeOfT.Field(idx)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := field.Name
+		if tag := field.Tag.Get("voltdb"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		colIndex := table.lookupColumn(name)
+		if colIndex < 0 {
+			continue
+		}
+		val, err := table.value(colIndex)
+		if err != nil {
+			return err
+		}
+		if err := assignValue(structVal.Field(idx), val); err != nil {
+			return fmt.Errorf("Can not decode column %v into field %v: %v",
+				table.columnNames[colIndex], field.Name, err)
+		}
+	}
+	return nil
+}
+
+// assignValue stores val, a value returned by Table.value, in field.
+func assignValue(field reflect.Value, val interface{}) error {
+	if val == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(field.Type()) {
+		field.Set(rv)
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(field.Type().Elem())
+		if err := assignValue(ptr.Elem(), val); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch rv.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if field.OverflowInt(rv.Int()) {
+				return fmt.Errorf("value %v overflows %v", val, field.Type())
+			}
+			field.SetInt(rv.Int())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if rv.Kind() == reflect.Float64 {
+			field.SetFloat(rv.Float())
+			return nil
+		}
+	}
+	return fmt.Errorf("type %T is not assignable to %v", val, field.Type())
+}
@@ -0,0 +1,88 @@
+package voltdb
+
+import (
+	"testing"
+)
+
+func decodeTestTable(t *testing.T) *Table {
+	return newTestTable(t,
+		[]testColumn{{"CONTESTANT_ID", vt_INT}, {"NAME", vt_STRING},
+			{"VOTES", vt_LONG}, {"EXTRA", vt_STRING}},
+		[][]interface{}{
+			{int32(1), "Edwina", int64(12), "ignored"},
+			{int32(2), nil, nil, nil},
+		})
+}
+
+func TestDecodeTagged(t *testing.T) {
+	type contestant struct {
+		ID    int32  `voltdb:"CONTESTANT_ID"`
+		Name  string `voltdb:"name"`
+		Votes int    `voltdb:"VOTES"`
+		Skip  string `voltdb:"-"`
+		Other string `voltdb:"MISSING"`
+	}
+	table := decodeTestTable(t)
+	var c contestant
+	if err := table.Decode(&c); err == nil {
+		t.Errorf("Expected error decoding before AdvanceRow")
+	}
+	table.AdvanceRow()
+	if err := table.Decode(&c); err != nil {
+		t.Fatalf("Decode produced error %v", err)
+	}
+	expected := contestant{ID: 1, Name: "Edwina", Votes: 12}
+	if c != expected {
+		t.Errorf("Decode has %+v wants %+v", c, expected)
+	}
+}
+
+func TestDecodeUntagged(t *testing.T) {
+	type contestant struct {
+		Name  string
+		Votes *int64
+		Extra *string
+		id    int32
+	}
+	table := decodeTestTable(t)
+	var c contestant
+	table.AdvanceRow()
+	if err := table.Decode(&c); err != nil {
+		t.Fatalf("Decode produced error %v", err)
+	}
+	if c.Name != "Edwina" || c.Votes == nil || *c.Votes != 12 ||
+		c.Extra == nil || *c.Extra != "ignored" || c.id != 0 {
+		t.Errorf("Bad Decode %+v", c)
+	}
+
+	table.AdvanceRow()
+	if err := table.Decode(&c); err != nil {
+		t.Fatalf("Decode produced error %v", err)
+	}
+	if c.Name != "" || c.Votes != nil || c.Extra != nil {
+		t.Errorf("Expected NULLs decoded as zero values, have %+v", c)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	table := decodeTestTable(t)
+	table.AdvanceRow()
+	var notStruct int
+	if err := table.Decode(&notStruct); err == nil {
+		t.Errorf("Expected error decoding into a non-struct")
+	}
+	var mismatch struct {
+		Name int32
+	}
+	if err := table.Decode(&mismatch); err == nil {
+		t.Errorf("Expected error decoding STRING into int32")
+	}
+	var overflow struct {
+		Votes int8
+	}
+	table = newTestTable(t, []testColumn{{"VOTES", vt_LONG}}, [][]interface{}{{int64(1000)}})
+	table.AdvanceRow()
+	if err := table.Decode(&overflow); err == nil {
+		t.Errorf("Expected overflow error, have %v", overflow.Votes)
+	}
+}
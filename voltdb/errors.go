@@ -0,0 +1,66 @@
+package voltdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Kinds of protocol violation. Decoders return a *ProtocolError that
+// matches one of them with errors.Is.
+var (
+	// ErrUnexpectedType reports a type byte that is unknown or is not
+	// the type expected, such as reading a STRING column as INTEGER.
+	ErrUnexpectedType = errors.New("Unexpected type.")
+	// ErrTruncatedMessage reports a message or table that ended early.
+	ErrTruncatedMessage = errors.New("Truncated message.")
+	// ErrProtocolVersion reports a message with an unsupported wire
+	// protocol version.
+	ErrProtocolVersion = errors.New("Unsupported protocol version.")
+	// ErrLengthMismatch reports a length prefix that disagrees with
+	// the data it covers.
+	ErrLengthMismatch = errors.New("Length mismatch.")
+	// ErrTrailingBytes reports a message longer than its contents,
+	// whose leftover bytes would otherwise be misread.
+	ErrTrailingBytes = errors.New("Trailing bytes.")
+	// ErrResponseTooLarge reports a message declared longer than the
+	// Conn accepts; see Conn.SetMaxResponseSize.
+	ErrResponseTooLarge = errors.New("Response too large.")
+)
+
+// ProtocolError describes a protocol violation of kind Kind.
+type ProtocolError struct {
+	Kind error
+	Msg  string
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Msg
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Kind
+}
+
+func protocolError(kind error, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{kind, fmt.Sprintf(format, args...)}
+}
+
+// truncated converts the short read errors of decoding a complete
+// message into ErrTruncatedMessage errors.
+func truncated(err error, what string) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return protocolError(ErrTruncatedMessage, "Truncated %v.", what)
+	}
+	return err
+}
+
+// checkConsumed returns an ErrTrailingBytes error if bytes of the
+// decoded what remain in b.
+func checkConsumed(b *bytes.Buffer, what string) error {
+	if b.Len() != 0 {
+		return protocolError(ErrTrailingBytes, "%d bytes follow the %v.", b.Len(), what)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package voltdb
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"testing"
+)
+
+func TestErrUnexpectedType(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"I", vt_INT}}, [][]interface{}{{int32(1)}})
+	table.AdvanceRow()
+	_, _, err := table.GetString(0)
+	if !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("Expected ErrUnexpectedType have %v", err)
+	}
+	var protoErr *ProtocolError
+	if !errors.As(err, &protoErr) || protoErr.Msg != "Column 0 has type 5 not 9." {
+		t.Errorf("Bad ProtocolError %v", err)
+	}
+	var s string
+	if err := table.Scan(&s); !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("Expected ErrUnexpectedType from Scan have %v", err)
+	}
+	if _, err := readArray(bytes.NewBuffer([]byte{byte(vt_TABLE), 0, 0})); !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("Expected ErrUnexpectedType from readArray have %v", err)
+	}
+}
+
+func TestErrTruncatedMessage(t *testing.T) {
+	var b bytes.Buffer
+	writeTestResponse(&b, 1, int8(SUCCESS), echoTable("truncated"))
+	payload, err := readMessage(&b)
+	if err != nil {
+		t.Fatalf("readMessage produced error %v", err)
+	}
+	for _, cut := range []int{3, 12, len(payload) - 1} {
+		_, err := deserializeCallResponse(bytes.NewBuffer(payload[:cut]))
+		if !errors.Is(err, ErrTruncatedMessage) {
+			t.Errorf("Expected ErrTruncatedMessage at %d bytes, have %v", cut, err)
+		}
+	}
+
+	b.Reset()
+	writeMessage(&b, []byte("payload"))
+	if _, err := readMessage(bytes.NewBuffer(b.Bytes()[:b.Len()-1])); !errors.Is(err, ErrTruncatedMessage) {
+		t.Errorf("Expected ErrTruncatedMessage from readMessage have %v", err)
+	}
+
+	b.Reset()
+	writeStreamTable(&b, 2, "abc")
+	stream, _ := NewRowStream(bytes.NewReader(b.Bytes()[:b.Len()-1]))
+	for stream.Next() {
+	}
+	if !errors.Is(stream.Err(), ErrTruncatedMessage) {
+		t.Errorf("Expected ErrTruncatedMessage from RowStream have %v", stream.Err())
+	}
+}
+
+func TestTableLengthMismatch(t *testing.T) {
+	if _, err := deserializeTable(bytes.NewReader(capturedTable)); err != nil {
+		t.Fatalf("deserializeTable produced error %v", err)
+	}
+	testVals := []struct {
+		name           string
+		ttlAdj, metAdj int32
+	}{
+		{"total length too long", 1, 0},
+		{"total length too short", -1, 0},
+		{"metadata length too long", 0, 1},
+		{"metadata length too short", 0, -1},
+		{"metadata longer than table", -40, 0},
+	}
+	for _, tv := range testVals {
+		data := append([]byte(nil), capturedTable...)
+		order.PutUint32(data, uint32(int32(order.Uint32(data))+tv.ttlAdj))
+		order.PutUint32(data[4:], uint32(int32(order.Uint32(data[4:]))+tv.metAdj))
+		// pad so that a longer table is not merely truncated.
+		data = append(data, 0)
+		if _, err := deserializeTable(bytes.NewReader(data)); !errors.Is(err, ErrLengthMismatch) {
+			t.Errorf("Expected ErrLengthMismatch for %v, have %v", tv.name, err)
+		}
+	}
+}
+
+func TestCorruptValueLength(t *testing.T) {
+	// the first row of capturedTable and the length of its STRING.
+	const rowAt, lengthAt = 35, 39
+	testVals := []struct {
+		length int32
+		err    error
+	}{
+		{-2, ErrLengthMismatch},
+		{math.MinInt32, ErrLengthMismatch},
+		{2, ErrTruncatedMessage},
+	}
+	for _, tv := range testVals {
+		data := append([]byte(nil), capturedTable...)
+		order.PutUint32(data[lengthAt:], uint32(tv.length))
+		table, err := deserializeTable(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("deserializeTable produced error %v", err)
+		}
+		if table.AdvanceRow() {
+			t.Errorf("Expected no row for value length %d", tv.length)
+		}
+		if !errors.Is(table.Err(), tv.err) {
+			t.Errorf("Bad Err for value length %d have %v wants %v", tv.length, table.Err(), tv.err)
+		}
+		var v struct{ A int32 }
+		if err := table.Decode(&v); !errors.Is(err, tv.err) {
+			t.Errorf("Bad Decode error for value length %d have %v wants %v", tv.length, err, tv.err)
+		}
+		row := data[rowAt : rowAt+9]
+		if _, err := columnOffsets([]int8{vt_INT, vt_STRING}, row); !errors.Is(err, tv.err) {
+			t.Errorf("Bad error for value length %d have %v wants %v", tv.length, err, tv.err)
+		}
+	}
+}
+
+func TestCorruptRowHelpers(t *testing.T) {
+	// the length of the STRING in the first row of capturedTable.
+	const lengthAt = 39
+	data := append([]byte(nil), capturedTable...)
+	order.PutUint32(data[lengthAt:], uint32(2))
+	corrupt := func() *Table {
+		table, err := deserializeTable(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("deserializeTable produced error %v", err)
+		}
+		return &table
+	}
+	helpers := map[string]func(*Table) error{
+		"Rows":        func(table *Table) error { _, err := table.Rows(); return err },
+		"ToMaps":      func(table *Table) error { _, err := table.ToMaps(); return err },
+		"WriteCSV":    func(table *Table) error { return table.WriteCSV(io.Discard) },
+		"MarshalJSON": func(table *Table) error { _, err := table.MarshalJSON(); return err },
+		"SumColumn":   func(table *Table) error { _, err := table.SumColumn(0); return err },
+		"sqlRows.Next": func(table *Table) error {
+			return (&sqlRows{table}).Next(make([]driver.Value, 2))
+		},
+	}
+	for name, helper := range helpers {
+		if err := helper(corrupt()); !errors.Is(err, ErrTruncatedMessage) {
+			t.Errorf("Expected ErrTruncatedMessage from %v have %v", name, err)
+		}
+	}
+}
+
+func TestRowStreamLengthMismatch(t *testing.T) {
+	data := append([]byte(nil), capturedTable...)
+	order.PutUint32(data, uint32(int32(order.Uint32(data))+4))
+	stream, err := NewRowStream(bytes.NewReader(append(data, 0, 0, 0, 0)))
+	if err != nil {
+		t.Fatalf("NewRowStream produced error %v", err)
+	}
+	for stream.Next() {
+	}
+	if !errors.Is(stream.Err(), ErrLengthMismatch) {
+		t.Errorf("Expected ErrLengthMismatch from RowStream have %v", stream.Err())
+	}
+}
+
+func TestErrTrailingBytes(t *testing.T) {
+	var b bytes.Buffer
+	writeTestResponse(&b, 1, int8(SUCCESS), echoTable("trailing"))
+	payload, err := readMessage(&b)
+	if err != nil {
+		t.Fatalf("readMessage produced error %v", err)
+	}
+	if _, err := decodeCallResponse(payload); err != nil {
+		t.Errorf("decodeCallResponse produced error %v", err)
+	}
+	// the declared length covers the extra bytes, so readMessage
+	// returns them and the next header is read in the right place.
+	b.Reset()
+	writeMessage(&b, append(payload, 0, 0, 0))
+	writeMessage(&b, payload)
+	extra, err := readMessage(&b)
+	if err != nil {
+		t.Fatalf("readMessage produced error %v", err)
+	}
+	if _, err := decodeCallResponse(extra); !errors.Is(err, ErrTrailingBytes) {
+		t.Errorf("Expected ErrTrailingBytes have %v", err)
+	}
+	if next, err := readMessage(&b); err != nil || !bytes.Equal(next, payload) {
+		t.Errorf("Bad message after trailing bytes have %x, %v", next, err)
+	}
+
+	if _, err := decodeLoginResponse(append(loginReply(), 0)); !errors.Is(err, ErrTrailingBytes) {
+		t.Errorf("Expected ErrTrailingBytes from login have %v", err)
+	}
+}
+
+func TestCallTrailingBytes(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		_, handle, _, err := readTestInvocation(c)
+		if err != nil {
+			return
+		}
+		var rsp bytes.Buffer
+		writeTestResponse(&rsp, handle, int8(SUCCESS), echoTable("Extra"))
+		payload, _ := readMessage(&rsp)
+		writeMessage(c, append(payload, 0xFF))
+		readTestInvocation(c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Call("Extra"); !errors.Is(err, ErrTrailingBytes) {
+		t.Errorf("Expected ErrTrailingBytes have %v", err)
+	}
+}
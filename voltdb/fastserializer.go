@@ -1,8 +1,13 @@
 package voltdb
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
+	"math/big"
+	"time"
 )
 
 // package private methods that perform voltdb compatible
@@ -13,19 +18,35 @@ const (
 	vt_ARRAY     int8 = -99 // array (short)(values*)
 	vt_NULL      int8 = 1   // null
 	vt_BOOL      int8 = 3   // boolean, byte
+	vt_TINYINT   int8 = 3   // int8, same wire type as vt_BOOL
 	vt_SHORT     int8 = 4   // int16
 	vt_INT       int8 = 5   // int32
 	vt_LONG      int8 = 6   // int64
-	vt_FLOAT     int8 = 8   // float64
+	vt_FLOAT     int8 = 8   // float64, a double: VoltDB FLOAT is 8 bytes
 	vt_STRING    int8 = 9   // string (int32-length-prefix)(utf-8 bytes)
 	vt_TIMESTAMP int8 = 11  // int64 timestamp microseconds
 	vt_TABLE     int8 = 21  // VoltTable
 	vt_DECIMAL   int8 = 22  // fix-scaled, fix-precision decimal
 	vt_VARBIN    int8 = 25  // varbinary (int)(bytes)
+	vt_POINT     int8 = 26  // geography point (float64 longitude)(float64 latitude)
+	vt_GEOGRAPHY int8 = 27  // geography polygon (int)(bytes)
 )
 
 var order = binary.BigEndian
 
+// SQL NULL is sent as a per-type sentinel value. Strings and
+// varbinary use a length prefix of -1.
+const (
+	byteNull  int8    = math.MinInt8
+	shortNull int16   = math.MinInt16
+	intNull   int32   = math.MinInt32
+	longNull  int64   = math.MinInt64
+	floatNull float64 = -1.7976931348623157e+308
+)
+
+// floatNullBits is the bit pattern of floatNull, -math.MaxFloat64.
+const floatNullBits uint64 = 0xFFEFFFFFFFFFFFFF
+
 // protoVersion is the implemented VoltDB wireprotocol version.
 const protoVersion = 1
 
@@ -64,30 +85,87 @@ func writeByte(w io.Writer, d int8) error {
 func readByte(r io.Reader) (int8, error) {
 	var b [1]byte
 	bs := b[:1]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
 	}
 	return int8(b[0]), nil
 }
 
+// readNullableByte reads a TINYINT and reports whether it was NULL.
+func readNullableByte(r io.Reader) (int8, bool, error) {
+	val, err := readByte(r)
+	if err != nil || val == byteNull {
+		return 0, err == nil, err
+	}
+	return val, false, nil
+}
+
 func readByteArray(r io.Reader) ([]int8, error) {
 	// byte arrays have 4 byte length prefixes.
 	cnt, err := readInt(r)
 	if err != nil {
 		return nil, err
 	}
+	if cnt < 0 {
+		return nil, protocolError(ErrLengthMismatch, "Invalid array count %d.", cnt)
+	}
+	bs, err := readBytes(r, int(cnt))
+	if err != nil {
+		return nil, err
+	}
 	arr := make([]int8, cnt)
-	for idx := range arr {
-		val, err := readByte(r)
-		if err != nil {
+	for idx, val := range bs {
+		arr[idx] = int8(val)
+	}
+	return arr, nil
+}
+
+// readBytes reads the n bytes of a length-prefixed value. The length
+// comes from the peer, so it is not trusted to size a buffer: a reader
+// that knows how many bytes it holds, such as the bytes.Reader of a
+// received message, fails a longer length before allocating, and any
+// other reader is read in chunks, so memory grows only as bytes arrive.
+func readBytes(r io.Reader, n int) ([]byte, error) {
+	if b, ok := r.(interface{ Len() int }); ok {
+		if n > b.Len() {
+			return nil, protocolError(ErrTruncatedMessage,
+				"Length %d exceeds the %d bytes remaining.", n, b.Len())
+		}
+		bs := make([]byte, n)
+		if _, err := io.ReadFull(r, bs); err != nil {
 			return nil, err
 		}
-		arr[idx] = val
+		return bs, nil
 	}
-	return arr, nil
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b.Bytes(), nil
 }
 
+func writeByteArray(w io.Writer, arr []int8) error {
+	// byte arrays have 4 byte length prefixes.
+	if err := writeLength(w, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeByte(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TINYINT, SMALLINT, INTEGER and BIGINT are signed two's complement
+// integers. The helpers below convert through the unsigned type of
+// the same width, which keeps the sign bit, so the extremes of each
+// type round trip; the minimum is also that type's NULL sentinel.
+
 func writeShort(w io.Writer, d int16) error {
 	var b [2]byte
 	bs := b[:2]
@@ -99,7 +177,7 @@ func writeShort(w io.Writer, d int16) error {
 func readShort(r io.Reader) (int16, error) {
 	var b [2]byte
 	bs := b[:2]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
 	}
@@ -107,6 +185,15 @@ func readShort(r io.Reader) (int16, error) {
 	return int16(result), nil
 }
 
+// readNullableShort reads a SMALLINT and reports whether it was NULL.
+func readNullableShort(r io.Reader) (int16, bool, error) {
+	val, err := readShort(r)
+	if err != nil || val == shortNull {
+		return 0, err == nil, err
+	}
+	return val, false, nil
+}
+
 func writeInt(w io.Writer, d int32) error {
 	var b [4]byte
 	bs := b[:4]
@@ -118,7 +205,7 @@ func writeInt(w io.Writer, d int32) error {
 func readInt(r io.Reader) (int32, error) {
 	var b [4]byte
 	bs := b[:4]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
 	}
@@ -126,6 +213,15 @@ func readInt(r io.Reader) (int32, error) {
 	return int32(result), nil
 }
 
+// readNullableInt reads an INTEGER and reports whether it was NULL.
+func readNullableInt(r io.Reader) (int32, bool, error) {
+	val, err := readInt(r)
+	if err != nil || val == intNull {
+		return 0, err == nil, err
+	}
+	return val, false, nil
+}
+
 func writeLong(w io.Writer, d int64) error {
 	var b [8]byte
 	bs := b[:8]
@@ -137,7 +233,7 @@ func writeLong(w io.Writer, d int64) error {
 func readLong(r io.Reader) (int64, error) {
 	var b [8]byte
 	bs := b[:8]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
 	}
@@ -145,10 +241,23 @@ func readLong(r io.Reader) (int64, error) {
 	return int64(result), nil
 }
 
+// readNullableLong reads a BIGINT and reports whether it was NULL.
+func readNullableLong(r io.Reader) (int64, bool, error) {
+	val, err := readLong(r)
+	if err != nil || val == longNull {
+		return 0, err == nil, err
+	}
+	return val, false, nil
+}
+
+// writeFloat writes the IEEE-754 bit pattern of d. VoltDB FLOAT
+// columns are doubles. NaN and the infinities are written as their
+// own bit patterns, none of which is the NULL sentinel, so they read
+// back unchanged and never as NULL.
 func writeFloat(w io.Writer, d float64) error {
 	var b [8]byte
 	bs := b[:8]
-	order.PutUint64(bs, uint64(d))
+	order.PutUint64(bs, math.Float64bits(d))
 	_, err := w.Write(bs)
 	return err
 }
@@ -156,52 +265,466 @@ func writeFloat(w io.Writer, d float64) error {
 func readFloat(r io.Reader) (float64, error) {
 	var b [8]byte
 	bs := b[:8]
-	_, err := r.Read(bs)
+	_, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
 	}
 	result := order.Uint64(bs)
-	return float64(result), nil
+	return math.Float64frombits(result), nil
+}
+
+// readNullableFloat reads a FLOAT and reports whether it was NULL.
+// Only the exact NULL bit pattern is NULL.
+func readNullableFloat(r io.Reader) (float64, bool, error) {
+	val, err := readFloat(r)
+	if err != nil {
+		return 0, false, err
+	}
+	if math.Float64bits(val) == floatNullBits {
+		return 0, true, nil
+	}
+	return val, false, nil
+}
+
+// timestampNull is the TIMESTAMP NULL sentinel.
+const timestampNull = longNull
+
+// writeTimestamp writes t as microseconds since the Unix epoch.
+// Sub-microsecond precision is truncated. The microseconds count the
+// instant t names, whatever its location, so equal instants in
+// different zones are written alike.
+func writeTimestamp(w io.Writer, t time.Time) error {
+	return writeLong(w, t.UnixMicro())
+}
+
+// readTimestamp reads a TIMESTAMP as a UTC time.Time. SQL NULL
+// timestamps are returned as the zero time.Time.
+func readTimestamp(r io.Reader) (time.Time, error) {
+	t, _, err := readNullableTimestamp(r)
+	return t, err
+}
+
+// readNullableTimestamp reads a TIMESTAMP and reports whether it
+// was the NULL sentinel.
+func readNullableTimestamp(r io.Reader) (time.Time, bool, error) {
+	us, err := readLong(r)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if us == timestampNull {
+		return time.Time{}, true, nil
+	}
+	return time.UnixMicro(us).UTC(), false, nil
+}
+
+// DECIMAL values are 16 byte big-endian two's complement integers
+// holding the value scaled by 10^decimalScale.
+const (
+	decimalScale     = 12
+	decimalPrecision = 38
+)
+
+var (
+	decimalScaleFactor = new(big.Int).Exp(big.NewInt(10), big.NewInt(decimalScale), nil)
+	decimalMaxUnscaled = new(big.Int).Exp(big.NewInt(10), big.NewInt(decimalPrecision), nil)
+	// the NULL decimal is the most negative 128 bit value.
+	decimalNull = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	twoTo128    = new(big.Int).Lsh(big.NewInt(1), 128)
+)
+
+// writeDecimal writes d as a VoltDB DECIMAL. A nil d writes NULL.
+// d must be exactly representable with 12 fractional digits, so
+// that nothing is silently truncated, and must fit in 38 digits of
+// precision. See RoundDecimal.
+func writeDecimal(w io.Writer, d *big.Rat) error {
+	var unscaled *big.Int
+	if d == nil {
+		unscaled = decimalNull
+	} else {
+		scaled := new(big.Rat).Mul(d, new(big.Rat).SetInt(decimalScaleFactor))
+		if !scaled.IsInt() {
+			return fmt.Errorf("Decimal %v has more than %d fractional digits.",
+				d.FloatString(decimalScale+1), decimalScale)
+		}
+		unscaled = scaled.Num()
+		if new(big.Int).Abs(unscaled).Cmp(decimalMaxUnscaled) >= 0 {
+			return fmt.Errorf("Decimal %v exceeds precision %d.",
+				d.FloatString(decimalScale), decimalPrecision)
+		}
+	}
+	twos := new(big.Int).Set(unscaled)
+	if twos.Sign() < 0 {
+		twos.Add(twos, twoTo128)
+	}
+	var b [16]byte
+	twos.FillBytes(b[:])
+	_, err := w.Write(b[:])
+	return err
+}
+
+// RoundDecimal returns d rounded to the 12 fractional digits of a
+// DECIMAL, with ties going to the even digit. A nil d is returned
+// as is.
+func RoundDecimal(d *big.Rat) *big.Rat {
+	if d == nil {
+		return nil
+	}
+	scaled := new(big.Rat).Mul(d, new(big.Rat).SetInt(decimalScaleFactor))
+	if scaled.IsInt() {
+		return new(big.Rat).Set(d)
+	}
+	q, r := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	// compare the remainder with half the denominator.
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	if cmp := half.Cmp(scaled.Denom()); cmp > 0 || (cmp == 0 && q.Bit(0) == 1) {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, decimalScaleFactor)
+}
+
+// readDecimal reads a VoltDB DECIMAL. NULL is returned as nil.
+func readDecimal(r io.Reader) (*big.Rat, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	unscaled := new(big.Int).SetBytes(b[:])
+	if b[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, twoTo128)
+	}
+	if unscaled.Cmp(decimalNull) == 0 {
+		return nil, nil
+	}
+	return new(big.Rat).SetFrac(unscaled, decimalScaleFactor), nil
+}
+
+// writeLength writes the int32 length prefix of a string or byte
+// string, refusing lengths that do not fit.
+func writeLength(w io.Writer, n int) error {
+	if int64(n) > math.MaxInt32 {
+		return fmt.Errorf("Length %d exceeds the maximum of %d.", n, math.MaxInt32)
+	}
+	return writeInt(w, int32(n))
 }
 
 func writeString(w io.Writer, d string) error {
-	writeInt(w, int32(len(d)))
+	if err := writeLength(w, len(d)); err != nil {
+		return err
+	}
 	_, err := io.WriteString(w, d)
 	return err
 }
 
+// readString reads a length-prefixed string. SQL NULL strings are
+// returned as "".
 func readString(r io.Reader) (result string, err error) {
-	result = ""
+	result, _, err = readNullableString(r)
+	return
+}
+
+// readNullableString reads a length-prefixed string and reports
+// whether the server sent the NULL marker (a length of -1).
+func readNullableString(r io.Reader) (result string, isNull bool, err error) {
 	length, err := readInt(r)
 	if err != nil {
 		return
 	}
-	bs := make([]byte, length)
-	_, err = r.Read(bs)
+	if length == -1 {
+		return "", true, nil
+	}
+	if length < -1 {
+		return "", false, protocolError(ErrLengthMismatch, "Invalid string length %d.", length)
+	}
+	bs, err := readBytes(r, int(length))
 	if err != nil {
 		return
 	}
-	return string(bs), nil
+	return string(bs), false, nil
 }
 
-func readStringArray(r io.Reader) ([]string, error) {
+// readCount reads the short element count of an array, which may not
+// be negative.
+func readCount(r io.Reader) (int, error) {
 	cnt, err := readShort(r)
+	if err != nil {
+		return 0, err
+	}
+	if cnt < 0 {
+		return 0, protocolError(ErrLengthMismatch, "Invalid array count %d.", cnt)
+	}
+	return int(cnt), nil
+}
+
+// readStringArray reads a short count and that many strings. A NULL
+// element, one with a length of -1, is "" in arr and true at the same
+// index of isNull, so it can be told from an empty string.
+func readStringArray(r io.Reader) (arr []string, isNull []bool, err error) {
+	cnt, err := readCount(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	arr = make([]string, cnt)
+	isNull = make([]bool, cnt)
+	for idx := range arr {
+		if arr[idx], isNull[idx], err = readNullableString(r); err != nil {
+			return nil, nil, err
+		}
+	}
+	return arr, isNull, nil
+}
+
+func writeStringArray(w io.Writer, arr []string) error {
+	if err := writeArrayCount(w, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeString(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTimestampArray reads a short count and that many TIMESTAMPs.
+// NULL elements are the zero time.Time, as with readTimestamp.
+func readTimestampArray(r io.Reader) ([]time.Time, error) {
+	cnt, err := readCount(r)
 	if err != nil {
 		return nil, err
 	}
-	arr := make([]string, cnt)
+	arr := make([]time.Time, cnt)
 	for idx := range arr {
-		val, err := readString(r)
+		if arr[idx], err = readTimestamp(r); err != nil {
+			return nil, err
+		}
+	}
+	return arr, nil
+}
+
+// writeTimestampArray writes arr as read by readTimestampArray. Zero
+// time.Time elements are written as NULL.
+func writeTimestampArray(w io.Writer, arr []time.Time) error {
+	if err := writeArrayCount(w, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		var err error
+		if val.IsZero() {
+			err = writeLong(w, timestampNull)
+		} else {
+			err = writeTimestamp(w, val)
+		}
 		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDecimalArray reads a short count and that many DECIMALs. NULL
+// elements are nil.
+func readDecimalArray(r io.Reader) ([]*big.Rat, error) {
+	cnt, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+	arr := make([]*big.Rat, cnt)
+	for idx := range arr {
+		if arr[idx], err = readDecimal(r); err != nil {
 			return nil, err
 		}
-		arr[idx] = val
 	}
 	return arr, nil
 }
 
+// writeDecimalArray writes arr as read by readDecimalArray. nil
+// elements are written as NULL.
+func writeDecimalArray(w io.Writer, arr []*big.Rat) error {
+	if err := writeArrayCount(w, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeDecimal(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Array parameters (vt_ARRAY) are an element type byte, a short
+// element count and the elements. TINYINT arrays use the 4 byte
+// length prefix of writeByteArray instead of a short count.
+
+func writeShortArray(w io.Writer, arr []int16) error {
+	if err := writeArrayHeader(w, vt_SHORT, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeShort(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeIntArray(w io.Writer, arr []int32) error {
+	if err := writeArrayHeader(w, vt_INT, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeInt(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLongArray(w io.Writer, arr []int64) error {
+	if err := writeArrayHeader(w, vt_LONG, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeLong(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFloatArray(w io.Writer, arr []float64) error {
+	if err := writeArrayHeader(w, vt_FLOAT, len(arr)); err != nil {
+		return err
+	}
+	for _, val := range arr {
+		if err := writeFloat(w, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArrayHeader(w io.Writer, elemType int8, cnt int) error {
+	if err := checkArrayCount(cnt); err != nil {
+		return err
+	}
+	if err := writeByte(w, elemType); err != nil {
+		return err
+	}
+	return writeShort(w, int16(cnt))
+}
+
+// writeArrayCount writes the short element count of an array, which
+// must fit in an int16.
+func writeArrayCount(w io.Writer, cnt int) error {
+	if err := checkArrayCount(cnt); err != nil {
+		return err
+	}
+	return writeShort(w, int16(cnt))
+}
+
+func checkArrayCount(cnt int) error {
+	if cnt > math.MaxInt16 {
+		return fmt.Errorf("Array of %d elements exceeds the %d element limit.",
+			cnt, math.MaxInt16)
+	}
+	return nil
+}
+
+// readArray reads an array parameter, dispatching on its element
+// type byte. The result is a []int8, []int16, []int32, []int64,
+// []float64, []string, [][]byte, []time.Time or []*big.Rat.
+func readArray(r io.Reader) (interface{}, error) {
+	elemType, err := readByte(r)
+	if err != nil {
+		return nil, err
+	}
+	if elemType == vt_BOOL {
+		return readByteArray(r)
+	}
+	switch elemType {
+	case vt_STRING:
+		// parameters are sent from a []string, which has no NULLs.
+		arr, _, err := readStringArray(r)
+		return arr, err
+	case vt_TIMESTAMP:
+		return readTimestampArray(r)
+	case vt_DECIMAL:
+		return readDecimalArray(r)
+	}
+	cnt, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+	switch elemType {
+	case vt_SHORT:
+		arr := make([]int16, cnt)
+		for idx := range arr {
+			if arr[idx], err = readShort(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case vt_INT:
+		arr := make([]int32, cnt)
+		for idx := range arr {
+			if arr[idx], err = readInt(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case vt_LONG:
+		arr := make([]int64, cnt)
+		for idx := range arr {
+			if arr[idx], err = readLong(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case vt_FLOAT:
+		arr := make([]float64, cnt)
+		for idx := range arr {
+			if arr[idx], err = readFloat(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case vt_VARBIN:
+		arr := make([][]byte, cnt)
+		for idx := range arr {
+			if arr[idx], err = readVarbinary(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, protocolError(ErrUnexpectedType, "Unsupported array element type %d.", elemType)
+}
+
 func writeByteString(w io.Writer, d []byte) error {
-	writeInt(w, int32(len(d)))
+	if err := writeLength(w, len(d)); err != nil {
+		return err
+	}
 	_, err := w.Write(d)
 	return err
 }
+
+// readVarbinary reads an int32-length-prefixed byte string as
+// written by writeByteString. SQL NULL is returned as nil.
+func readVarbinary(r io.Reader) ([]byte, error) {
+	length, err := readInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if length == -1 {
+		return nil, nil
+	}
+	if length < -1 {
+		return nil, protocolError(ErrLengthMismatch, "Invalid varbinary length %d.", length)
+	}
+	return readBytes(r, int(length))
+}
@@ -2,7 +2,14 @@ package voltdb
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestWriteByte(t *testing.T) {
@@ -91,6 +98,285 @@ func TestWriteFloat(t *testing.T) {
 	}
 }
 
+func TestRoundTripFloat(t *testing.T) {
+	testVals := [...]float64{3.14, -2.5, 0.0, 1e-300, -100.1,
+		math.Inf(1), math.Inf(-1), -1.7976931348623157e+308}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeFloat(&b, val)
+		r, _ := readFloat(&b)
+		if val != r {
+			t.Errorf("Expected %v have %v", val, r)
+		}
+	}
+}
+
+func TestRoundTripFloatNaN(t *testing.T) {
+	var b bytes.Buffer
+	writeFloat(&b, math.NaN())
+	r, _ := readFloat(&b)
+	if !math.IsNaN(r) {
+		t.Errorf("Expected NaN have %v", r)
+	}
+}
+
+// reads each fixed-width type through a reader that returns one
+// byte per Read call.
+func TestReadFixedWidthOneByteReader(t *testing.T) {
+	var b bytes.Buffer
+	writeBoolean(&b, true)
+	writeByte(&b, -7)
+	writeShort(&b, 0x4BCD)
+	writeInt(&b, -0x12345678)
+	writeLong(&b, 0x123456789ABCDEF)
+	writeFloat(&b, -2.75)
+	r := iotest.OneByteReader(&b)
+
+	if v, err := readBoolean(r); err != nil || v != true {
+		t.Errorf("readBoolean have %v, %v", v, err)
+	}
+	if v, err := readByte(r); err != nil || v != -7 {
+		t.Errorf("readByte have %v, %v", v, err)
+	}
+	if v, err := readShort(r); err != nil || v != 0x4BCD {
+		t.Errorf("readShort have %v, %v", v, err)
+	}
+	if v, err := readInt(r); err != nil || v != -0x12345678 {
+		t.Errorf("readInt have %v, %v", v, err)
+	}
+	if v, err := readLong(r); err != nil || v != 0x123456789ABCDEF {
+		t.Errorf("readLong have %v, %v", v, err)
+	}
+	if v, err := readFloat(r); err != nil || v != -2.75 {
+		t.Errorf("readFloat have %v, %v", v, err)
+	}
+}
+
+func TestReadFixedWidthTruncated(t *testing.T) {
+	b := bytes.NewBuffer([]byte{0x00, 0x01})
+	if _, err := readInt(b); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF have %v", err)
+	}
+}
+
+func TestRoundTripTimestamp(t *testing.T) {
+	testVals := [...]time.Time{
+		time.Unix(0, 0),
+		time.Date(2012, 5, 17, 10, 30, 15, 123456000, time.UTC),
+		time.Date(1960, 1, 1, 0, 0, 0, 1000, time.UTC),
+	}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeTimestamp(&b, val)
+		r, err := readTimestamp(&b)
+		if err != nil || !r.Equal(val) || r.Location() != time.UTC {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+		}
+	}
+}
+
+func TestTimestampTruncatesNanoseconds(t *testing.T) {
+	testVals := [...]time.Time{
+		time.Date(2012, 5, 17, 10, 30, 15, 123456789, time.UTC),
+		time.Date(1960, 1, 1, 0, 0, 0, 999, time.UTC),
+	}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeTimestamp(&b, val)
+		r, _ := readTimestamp(&b)
+		expected := val.Truncate(time.Microsecond)
+		if !r.Equal(expected) {
+			t.Errorf("Expected %v have %v", expected, r)
+		}
+	}
+}
+
+func TestTimestampNull(t *testing.T) {
+	var b bytes.Buffer
+	writeLong(&b, math.MinInt64)
+	r, isNull, err := readNullableTimestamp(&b)
+	if err != nil || !isNull || !r.IsZero() {
+		t.Errorf("Expected NULL timestamp have %v, %v, %v", r, isNull, err)
+	}
+}
+
+func TestRoundTripDecimal(t *testing.T) {
+	testVals := [...]string{
+		"0",
+		"1.5",
+		"-1.5",
+		"123456.000000000001",
+		"-0.000000000001",
+		"99999999999999999999999999.999999999999",
+		"-99999999999999999999999999.999999999999",
+	}
+	for _, str := range testVals {
+		val, _ := new(big.Rat).SetString(str)
+		var b bytes.Buffer
+		if err := writeDecimal(&b, val); err != nil {
+			t.Errorf("writeDecimal produced error %v for %v", err, str)
+			continue
+		}
+		if b.Len() != 16 {
+			t.Errorf("writeDecimal wrote %v bytes expected 16 bytes", b.Len())
+		}
+		r, err := readDecimal(&b)
+		if err != nil || r == nil || r.Cmp(val) != 0 {
+			t.Errorf("Expected %v have %v, %v", str, r, err)
+		}
+	}
+}
+
+func TestWriteDecimalEncoding(t *testing.T) {
+	var b bytes.Buffer
+	// -1 scaled by 10^12 is -0xE8D4A51000.
+	writeDecimal(&b, big.NewRat(-1, 1))
+	expected := [...]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFF, 0xFF, 0xFF, 0x17, 0x2B, 0x5A, 0xF0, 0x00}
+	for idx, val := range expected {
+		actual, _ := b.ReadByte()
+		if val != actual {
+			t.Errorf("writeDecimal at index %v has %x wants %x", idx, actual, val)
+		}
+	}
+}
+
+func TestDecimalNull(t *testing.T) {
+	var b bytes.Buffer
+	writeDecimal(&b, nil)
+	if first, _ := b.ReadByte(); first != 0x80 {
+		t.Errorf("NULL decimal has leading byte %x wants 80", first)
+	}
+	b.Reset()
+	writeDecimal(&b, nil)
+	r, err := readDecimal(&b)
+	if err != nil || r != nil {
+		t.Errorf("Expected NULL decimal have %v, %v", r, err)
+	}
+}
+
+func TestWriteDecimalErrors(t *testing.T) {
+	var b bytes.Buffer
+	tooPrecise, _ := new(big.Rat).SetString("0.0000000000001")
+	if err := writeDecimal(&b, tooPrecise); err == nil {
+		t.Errorf("Expected error writing decimal with 13 fractional digits")
+	}
+	tooLarge, _ := new(big.Rat).SetString("100000000000000000000000000")
+	if err := writeDecimal(&b, tooLarge); err == nil {
+		t.Errorf("Expected error writing decimal exceeding precision")
+	}
+}
+
+func TestRoundDecimal(t *testing.T) {
+	cases := map[string]string{
+		"1.5":                "1.500000000000",
+		"0.0000000000005":    "0.000000000000",
+		"0.0000000000015":    "0.000000000002",
+		"0.00000000000051":   "0.000000000001",
+		"-0.0000000000015":   "-0.000000000002",
+		"-0.00000000000049":  "0.000000000000",
+		"2.99999999999999":   "3.000000000000",
+		"-123.4567890123456": "-123.456789012346",
+	}
+	for in, expected := range cases {
+		d, _ := new(big.Rat).SetString(in)
+		rounded := RoundDecimal(d)
+		if s := rounded.FloatString(decimalScale); s != expected {
+			t.Errorf("RoundDecimal(%v) has %v wants %v", in, s, expected)
+		}
+		var b bytes.Buffer
+		if err := writeDecimal(&b, rounded); err != nil {
+			t.Errorf("writeDecimal of RoundDecimal(%v) produced error %v", in, err)
+		}
+	}
+	if RoundDecimal(nil) != nil {
+		t.Errorf("Expected RoundDecimal(nil) to be nil")
+	}
+}
+
+func TestRoundDecimalOverflow(t *testing.T) {
+	// 26 integer digits round up to 27.
+	d, _ := new(big.Rat).SetString("99999999999999999999999999.9999999999999")
+	var b bytes.Buffer
+	if err := writeDecimal(&b, RoundDecimal(d)); err == nil {
+		t.Errorf("Expected error writing a rounded decimal exceeding precision")
+	}
+}
+
+func TestNullableReaders(t *testing.T) {
+	var b bytes.Buffer
+	writeByte(&b, math.MinInt8)
+	writeShort(&b, math.MinInt16)
+	writeInt(&b, math.MinInt32)
+	writeLong(&b, math.MinInt64)
+	writeFloat(&b, -1.7976931348623157e+308)
+	if _, isNull, err := readNullableByte(&b); !isNull || err != nil {
+		t.Errorf("readNullableByte missed NULL: %v", err)
+	}
+	if _, isNull, err := readNullableShort(&b); !isNull || err != nil {
+		t.Errorf("readNullableShort missed NULL: %v", err)
+	}
+	if _, isNull, err := readNullableInt(&b); !isNull || err != nil {
+		t.Errorf("readNullableInt missed NULL: %v", err)
+	}
+	if _, isNull, err := readNullableLong(&b); !isNull || err != nil {
+		t.Errorf("readNullableLong missed NULL: %v", err)
+	}
+	if _, isNull, err := readNullableFloat(&b); !isNull || err != nil {
+		t.Errorf("readNullableFloat missed NULL: %v", err)
+	}
+
+	b.Reset()
+	writeByte(&b, math.MinInt8+1)
+	writeShort(&b, math.MinInt16+1)
+	writeInt(&b, math.MinInt32+1)
+	writeLong(&b, math.MinInt64+1)
+	writeFloat(&b, -1.5)
+	if v, isNull, _ := readNullableByte(&b); isNull || v != math.MinInt8+1 {
+		t.Errorf("readNullableByte have %v, %v", v, isNull)
+	}
+	if v, isNull, _ := readNullableShort(&b); isNull || v != math.MinInt16+1 {
+		t.Errorf("readNullableShort have %v, %v", v, isNull)
+	}
+	if v, isNull, _ := readNullableInt(&b); isNull || v != math.MinInt32+1 {
+		t.Errorf("readNullableInt have %v, %v", v, isNull)
+	}
+	if v, isNull, _ := readNullableLong(&b); isNull || v != math.MinInt64+1 {
+		t.Errorf("readNullableLong have %v, %v", v, isNull)
+	}
+	if v, isNull, _ := readNullableFloat(&b); isNull || v != -1.5 {
+		t.Errorf("readNullableFloat have %v, %v", v, isNull)
+	}
+}
+
+func TestNullFloatBits(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{0xFF, 0xEF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	if _, isNull, err := readNullableFloat(&b); !isNull || err != nil {
+		t.Errorf("readNullableFloat missed the NULL bit pattern: %v", err)
+	}
+	if math.Float64bits(floatNull) != floatNullBits {
+		t.Errorf("floatNull has bits %x wants %x", math.Float64bits(floatNull), floatNullBits)
+	}
+
+	near := []float64{math.Nextafter(floatNull, 0), math.Inf(-1),
+		-math.MaxFloat64 / 2, math.MaxFloat64}
+	for _, val := range near {
+		b.Reset()
+		writeFloat(&b, val)
+		if v, isNull, err := readNullableFloat(&b); isNull || v != val || err != nil {
+			t.Errorf("readNullableFloat(%v) have %v, %v, %v", val, v, isNull, err)
+		}
+	}
+}
+
+func TestNullableReadersTruncated(t *testing.T) {
+	var b bytes.Buffer
+	if _, isNull, err := readNullableInt(&b); err == nil || isNull {
+		t.Errorf("Expected error and non-NULL, have %v, %v", isNull, err)
+	}
+}
+
 // only tests a single pure-ascii string
 func TestWriteString(t *testing.T) {
 	var b bytes.Buffer
@@ -110,6 +396,24 @@ func TestWriteString(t *testing.T) {
 	}
 }
 
+func TestWriteLength(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeLength(&b, math.MaxInt32); err != nil {
+		t.Errorf("writeLength produced error %v at the limit", err)
+	}
+	if math.MaxInt == math.MaxInt32 {
+		t.Skip("int can not exceed the limit")
+	}
+	b.Reset()
+	tooLong := int64(math.MaxInt32) + 1
+	if err := writeLength(&b, int(tooLong)); err == nil {
+		t.Errorf("Expected error for length %v", tooLong)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Expected nothing written, have %v bytes", b.Len())
+	}
+}
+
 func TestRoundTripString(t *testing.T) {
 	val := "⋒♈ℱ8 ♈ᗴᔕ♈ ᔕ♈ᖇᓰﬡᘐ"
 	var b bytes.Buffer
@@ -120,6 +424,266 @@ func TestRoundTripString(t *testing.T) {
 	}
 }
 
+func TestReadStringOneByteReader(t *testing.T) {
+	val := "a string split across many reads"
+	var b bytes.Buffer
+	writeString(&b, val)
+	result, err := readString(iotest.OneByteReader(&b))
+	if err != nil {
+		t.Errorf("readString produced error %v", err)
+	}
+	if val != result {
+		t.Errorf("expected %v received %v", val, result)
+	}
+}
+
+func TestReadNullString(t *testing.T) {
+	var b bytes.Buffer
+	writeInt(&b, -1)
+	result, isNull, err := readNullableString(&b)
+	if err != nil || !isNull || result != "" {
+		t.Errorf("expected NULL string, have %q, %v, %v", result, isNull, err)
+	}
+
+	b.Reset()
+	writeString(&b, "")
+	result, isNull, err = readNullableString(&b)
+	if err != nil || isNull || result != "" {
+		t.Errorf("expected empty string, have %q, %v, %v", result, isNull, err)
+	}
+}
+
+func TestReadStringBadLength(t *testing.T) {
+	for _, length := range []int32{-2, math.MinInt32} {
+		var b bytes.Buffer
+		writeInt(&b, length)
+		b.WriteString("data")
+		if _, err := readString(&b); !errors.Is(err, ErrLengthMismatch) {
+			t.Errorf("Expected ErrLengthMismatch for length %d have %v", length, err)
+		}
+	}
+}
+
+func TestRoundTripVarbinary(t *testing.T) {
+	testVals := [...][]byte{{0x00, 0xFF, 0x10}, {}}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeByteString(&b, val)
+		r, err := readVarbinary(iotest.OneByteReader(&b))
+		if err != nil || r == nil || !bytes.Equal(val, r) {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+		}
+	}
+
+	var b bytes.Buffer
+	writeInt(&b, -1)
+	r, err := readVarbinary(&b)
+	if err != nil || r != nil {
+		t.Errorf("Expected NULL varbinary have %v, %v", r, err)
+	}
+}
+
+func TestReadVarbinaryBadLength(t *testing.T) {
+	for _, length := range []int32{-2, math.MinInt32} {
+		var b bytes.Buffer
+		writeInt(&b, length)
+		b.WriteString("data")
+		if _, err := readVarbinary(&b); !errors.Is(err, ErrLengthMismatch) {
+			t.Errorf("Expected ErrLengthMismatch for length %d have %v", length, err)
+		}
+		b.Reset()
+		writeInt(&b, length)
+		if _, err := readByteArray(&b); !errors.Is(err, ErrLengthMismatch) {
+			t.Errorf("Expected ErrLengthMismatch from readByteArray for count %d have %v", length, err)
+		}
+	}
+}
+
+func TestRoundTripByteArray(t *testing.T) {
+	testVals := [...][]int8{{}, {-128, 0, 127}, make([]int8, 300)}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeByteArray(&b, val)
+		r, err := readByteArray(&b)
+		if err != nil || len(r) != len(val) {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+			continue
+		}
+		for idx := range val {
+			if val[idx] != r[idx] {
+				t.Errorf("at index %v expected %v have %v", idx, val[idx], r[idx])
+			}
+		}
+	}
+}
+
+func TestRoundTripStringArray(t *testing.T) {
+	testVals := [...][]string{{}, {"a"}, {"abc", "", "⋒♈ℱ8"}}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeStringArray(&b, val)
+		r, isNull, err := readStringArray(&b)
+		if err != nil || len(r) != len(val) || len(isNull) != len(val) {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+			continue
+		}
+		for idx := range val {
+			if val[idx] != r[idx] || isNull[idx] {
+				t.Errorf("at index %v expected %v have %v, %v", idx, val[idx], r[idx], isNull[idx])
+			}
+		}
+	}
+}
+
+func TestReadStringArrayNull(t *testing.T) {
+	var b bytes.Buffer
+	writeShort(&b, 3)
+	writeString(&b, "a")
+	writeInt(&b, -1) // NULL
+	writeString(&b, "")
+	arr, isNull, err := readStringArray(&b)
+	if err != nil {
+		t.Fatalf("readStringArray produced error %v", err)
+	}
+	if !reflect.DeepEqual(arr, []string{"a", "", ""}) || !reflect.DeepEqual(isNull, []bool{false, true, false}) {
+		t.Errorf("Bad string array have %q, %v", arr, isNull)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Expected the NULL element to be consumed, %d bytes left", b.Len())
+	}
+}
+
+func TestReadArrayNegativeCount(t *testing.T) {
+	var b bytes.Buffer
+	writeShort(&b, -1)
+	if _, _, err := readStringArray(&b); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("Expected ErrLengthMismatch from readStringArray have %v", err)
+	}
+	for _, elemType := range []int8{vt_STRING, vt_SHORT, vt_INT, vt_LONG, vt_FLOAT,
+		vt_VARBIN, vt_TIMESTAMP, vt_DECIMAL} {
+		b.Reset()
+		writeByte(&b, elemType)
+		writeShort(&b, math.MinInt16)
+		if _, err := readArray(&b); !errors.Is(err, ErrLengthMismatch) {
+			t.Errorf("Expected ErrLengthMismatch for element type %d have %v", elemType, err)
+		}
+	}
+}
+
+func TestRoundTripTimestampArray(t *testing.T) {
+	ts := time.Date(2017, 3, 4, 5, 6, 7, 8000, time.UTC)
+	testVals := [...][]time.Time{{}, {ts}, {time.Unix(0, 0).UTC(), {}, ts}}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeTimestampArray(&b, val)
+		r, err := readTimestampArray(&b)
+		if err != nil || len(r) != len(val) {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+			continue
+		}
+		for idx := range val {
+			if !val[idx].Equal(r[idx]) {
+				t.Errorf("at index %v expected %v have %v", idx, val[idx], r[idx])
+			}
+		}
+	}
+}
+
+func TestRoundTripDecimalArray(t *testing.T) {
+	testVals := [...][]*big.Rat{{}, {big.NewRat(3, 2)}, {big.NewRat(-1, 1000), nil, new(big.Rat)}}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		writeDecimalArray(&b, val)
+		r, err := readDecimalArray(&b)
+		if err != nil || len(r) != len(val) {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+			continue
+		}
+		for idx := range val {
+			if (val[idx] == nil) != (r[idx] == nil) || (val[idx] != nil && val[idx].Cmp(r[idx]) != 0) {
+				t.Errorf("at index %v expected %v have %v", idx, val[idx], r[idx])
+			}
+		}
+	}
+}
+
+func TestReadArrayTimestampsAndDecimals(t *testing.T) {
+	var b bytes.Buffer
+	ts := time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC)
+	writeByte(&b, vt_TIMESTAMP)
+	writeTimestampArray(&b, []time.Time{ts, {}})
+	writeByte(&b, vt_DECIMAL)
+	writeDecimalArray(&b, []*big.Rat{nil, big.NewRat(1, 4)})
+
+	if r, err := readArray(&b); err != nil || !reflect.DeepEqual(r, []time.Time{ts, {}}) {
+		t.Errorf("Bad timestamp array have %v, %v", r, err)
+	}
+	r, err := readArray(&b)
+	if arr, ok := r.([]*big.Rat); err != nil || !ok || len(arr) != 2 ||
+		arr[0] != nil || arr[1].Cmp(big.NewRat(1, 4)) != 0 {
+		t.Errorf("Bad decimal array have %v, %v", r, err)
+	}
+}
+
+func TestRoundTripArrays(t *testing.T) {
+	var b bytes.Buffer
+	shorts := []int16{-32768, 0, 32767}
+	ints := []int32{-1, 0, 0x7FFFFFFF}
+	longs := []int64{}
+	floats := []float64{3.14, -0.5}
+	writeShortArray(&b, shorts)
+	writeIntArray(&b, ints)
+	writeLongArray(&b, longs)
+	writeFloatArray(&b, floats)
+	b.WriteByte(byte(vt_BOOL))
+	writeByteArray(&b, []int8{1, 2})
+	b.WriteByte(byte(vt_STRING))
+	writeStringArray(&b, []string{"x", "y"})
+
+	expected := []interface{}{shorts, ints, longs, floats,
+		[]int8{1, 2}, []string{"x", "y"}}
+	for _, val := range expected {
+		r, err := readArray(&b)
+		if err != nil || !reflect.DeepEqual(val, r) {
+			t.Errorf("Expected %v have %v, %v", val, r, err)
+		}
+	}
+}
+
+func TestWriteArrayTooLong(t *testing.T) {
+	n := math.MaxInt16 + 1
+	writers := map[string]func(*bytes.Buffer) error{
+		"string":    func(b *bytes.Buffer) error { return writeStringArray(b, make([]string, n)) },
+		"timestamp": func(b *bytes.Buffer) error { return writeTimestampArray(b, make([]time.Time, n)) },
+		"decimal":   func(b *bytes.Buffer) error { return writeDecimalArray(b, make([]*big.Rat, n)) },
+		"short":     func(b *bytes.Buffer) error { return writeShortArray(b, make([]int16, n)) },
+	}
+	for name, write := range writers {
+		var b bytes.Buffer
+		if err := write(&b); err == nil {
+			t.Errorf("Expected error writing a %v array of %v elements", name, n)
+		}
+		if b.Len() != 0 {
+			t.Errorf("Bad %v array wrote %v bytes wants 0", name, b.Len())
+		}
+	}
+
+	var b bytes.Buffer
+	if err := writeStringArray(&b, make([]string, math.MaxInt16)); err != nil {
+		t.Fatalf("writeStringArray produced error %v", err)
+	}
+	if arr, _, err := readStringArray(&b); len(arr) != math.MaxInt16 || err != nil {
+		t.Errorf("Bad string array have %v elements, %v wants %v", len(arr), err, math.MaxInt16)
+	}
+}
+
+func TestReadArrayUnknownType(t *testing.T) {
+	b := bytes.NewBuffer([]byte{byte(vt_TABLE), 0x00, 0x00})
+	if _, err := readArray(b); err == nil {
+		t.Errorf("Expected error reading array of unsupported type")
+	}
+}
+
 func TestReflection(t *testing.T) {
 	var b bytes.Buffer
 	var expInt8 int8 = 5
@@ -145,3 +709,94 @@ func TestReflection(t *testing.T) {
 		t.Errorf("string reflection failed. Want %s have %s", expString, rString)
 	}
 }
+
+func TestSpecialFloats(t *testing.T) {
+	specials := []float64{math.NaN(), math.Inf(1), math.Inf(-1)}
+	sameFloat := func(a, b float64) bool {
+		return math.Float64bits(a) == math.Float64bits(b)
+	}
+
+	var b bytes.Buffer
+	params := []interface{}{specials[0], specials[1], specials[2]}
+	if err := writeParameterSet(&b, params); err != nil {
+		t.Fatalf("writeParameterSet produced error %v", err)
+	}
+	decoded, err := readParameterSet(&b)
+	if err != nil {
+		t.Fatalf("readParameterSet produced error %v", err)
+	}
+	for idx, val := range specials {
+		if f, ok := decoded[idx].(float64); !ok || !sameFloat(f, val) {
+			t.Errorf("Parameter %v read back as %v", val, decoded[idx])
+		}
+	}
+
+	table := newTestTable(t, []testColumn{{"F", vt_FLOAT}},
+		[][]interface{}{{specials[0]}, {specials[1]}, {specials[2]}, {nil}})
+	for _, val := range specials {
+		table.AdvanceRow()
+		if f, isNull, err := table.GetFloat(0); !sameFloat(f, val) || isNull || err != nil {
+			t.Errorf("GetFloat of %v has %v, %v, %v", val, f, isNull, err)
+		}
+	}
+	table.AdvanceRow()
+	if _, isNull, err := table.GetFloat(0); !isNull || err != nil {
+		t.Errorf("Expected NULL after the special values, have %v, %v", isNull, err)
+	}
+}
+
+func TestSignedIntegerBounds(t *testing.T) {
+	var b bytes.Buffer
+	for _, v := range []int16{math.MinInt16, -1, 0, 1, math.MaxInt16} {
+		b.Reset()
+		writeShort(&b, v)
+		if r, err := readShort(&b); r != v || err != nil {
+			t.Errorf("Bad short have %v, %v wants %v", r, err, v)
+		}
+	}
+	for _, v := range []int32{math.MinInt32, -1, 0, 1, math.MaxInt32} {
+		b.Reset()
+		writeInt(&b, v)
+		if r, err := readInt(&b); r != v || err != nil {
+			t.Errorf("Bad int have %v, %v wants %v", r, err, v)
+		}
+	}
+	for _, v := range []int64{math.MinInt64, -1, 0, 1, math.MaxInt64} {
+		b.Reset()
+		writeLong(&b, v)
+		if r, err := readLong(&b); r != v || err != nil {
+			t.Errorf("Bad long have %v, %v wants %v", r, err, v)
+		}
+	}
+
+	// the wire form is big-endian two's complement
+	b.Reset()
+	writeShort(&b, math.MinInt16)
+	writeInt(&b, -2)
+	writeLong(&b, math.MaxInt64)
+	expected := []byte{0x80, 0x00, 0xFF, 0xFF, 0xFF, 0xFE,
+		0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	if !bytes.Equal(b.Bytes(), expected) {
+		t.Errorf("Bad encoding have %x wants %x", b.Bytes(), expected)
+	}
+}
+
+func TestTimestampLocation(t *testing.T) {
+	zones := []*time.Location{time.FixedZone("UTC+9", 9*3600),
+		time.FixedZone("UTC-5:30", -(5*3600 + 1800)), time.Local}
+	utc := time.Date(2020, 2, 29, 23, 30, 0, 250000, time.UTC)
+	var expected bytes.Buffer
+	writeLong(&expected, utc.Unix()*1000000+250)
+	for _, zone := range zones {
+		local := utc.In(zone)
+		var b bytes.Buffer
+		writeTimestamp(&b, local)
+		if !bytes.Equal(b.Bytes(), expected.Bytes()) {
+			t.Errorf("%v wrote %x wants %x", zone, b.Bytes(), expected.Bytes())
+		}
+		r, err := readTimestamp(&b)
+		if err != nil || !r.Equal(local) || r.Location() != time.UTC {
+			t.Errorf("%v read back as %v, %v wants %v", zone, r, err, utc)
+		}
+	}
+}
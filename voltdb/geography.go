@@ -0,0 +1,223 @@
+package voltdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// geography.go de/serializes the VoltDB geospatial types.
+
+// GeographyPoint is a GEOGRAPHY_POINT value, in degrees.
+type GeographyPoint struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// pointNullCoord is the value of both coordinates of a NULL point.
+const pointNullCoord = 360.0
+
+// writeGeographyPoint writes p as its longitude and latitude. A nil
+// p is written as NULL.
+func writeGeographyPoint(w io.Writer, p *GeographyPoint) error {
+	lng, lat := pointNullCoord, pointNullCoord
+	if p != nil {
+		lng, lat = p.Longitude, p.Latitude
+	}
+	if err := writeFloat(w, lng); err != nil {
+		return err
+	}
+	return writeFloat(w, lat)
+}
+
+// readGeographyPoint reads a point written by writeGeographyPoint
+// and reports whether it is NULL.
+func readGeographyPoint(r io.Reader) (GeographyPoint, bool, error) {
+	lng, err := readFloat(r)
+	if err != nil {
+		return GeographyPoint{}, false, err
+	}
+	lat, err := readFloat(r)
+	if err != nil {
+		return GeographyPoint{}, false, err
+	}
+	if lng == pointNullCoord && lat == pointNullCoord {
+		return GeographyPoint{}, true, nil
+	}
+	return GeographyPoint{lng, lat}, false, nil
+}
+
+// Geography is a GEOGRAPHY value: a polygon given as rings of points.
+// The first ring is the outer shell and any others are holes. Each
+// ring is closed, its last point repeating its first; shells run
+// counter-clockwise and holes clockwise.
+type Geography struct {
+	Rings [][]GeographyPoint
+}
+
+// On the wire a GEOGRAPHY is an int32 length, -1 for NULL, followed
+// by the server's polygon encoding:
+//
+//	polygon: (byte version)(byte owns loops)(byte has holes)
+//	         (int32 loop count)(loop*)(bound)
+//	loop:    (byte version)(int32 vertex count)(vertex*)
+//	         (byte origin inside)(int32 depth)(bound)
+//	vertex:  (float64 x)(float64 y)(float64 z), a point on the unit sphere
+//	bound:   (byte version)(float64 lat lo)(float64 lat hi)
+//	         (float64 lng lo)(float64 lng hi)
+//
+// Loops omit the closing vertex and holes are stored in the reverse
+// of their ring order. Clients send an empty bound and the server
+// computes the real one.
+const (
+	geographyVersion = 0
+	minRingPoints    = 4
+)
+
+// writeGeography writes g, or NULL if g is nil.
+func writeGeography(w io.Writer, g *Geography) error {
+	if g == nil {
+		return writeInt(w, -1)
+	}
+	var b bytes.Buffer
+	writeByte(&b, geographyVersion)
+	writeByte(&b, 1) // owns loops
+	if len(g.Rings) > 1 {
+		writeByte(&b, 1)
+	} else {
+		writeByte(&b, 0)
+	}
+	writeInt(&b, int32(len(g.Rings)))
+	for idx, ring := range g.Rings {
+		if len(ring) < minRingPoints || ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("Ring %d must be closed and have at least %d points.",
+				idx, minRingPoints)
+		}
+		vertices := ring[:len(ring)-1]
+		writeByte(&b, geographyVersion)
+		writeInt(&b, int32(len(vertices)))
+		for i := range vertices {
+			p := vertices[i]
+			if idx > 0 && i > 0 {
+				p = vertices[len(vertices)-i]
+			}
+			x, y, z := p.xyz()
+			writeFloat(&b, x)
+			writeFloat(&b, y)
+			writeFloat(&b, z)
+		}
+		writeByte(&b, 0) // origin inside
+		writeInt(&b, 0)  // depth
+		writeEmptyBound(&b)
+	}
+	writeEmptyBound(&b)
+	return writeByteString(w, b.Bytes())
+}
+
+func writeEmptyBound(w io.Writer) {
+	writeByte(w, geographyVersion)
+	writeFloat(w, 1)
+	writeFloat(w, 0)
+	writeFloat(w, math.Pi)
+	writeFloat(w, -math.Pi)
+}
+
+// readGeography reads a value written by writeGeography. It returns
+// nil for NULL.
+func readGeography(r io.Reader) (*Geography, error) {
+	data, err := readVarbinary(r)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	b := bytes.NewReader(data)
+	// version, owns loops and has holes.
+	if _, err = io.ReadFull(b, make([]byte, 3)); err != nil {
+		return nil, err
+	}
+	loops, err := readInt(b)
+	if err != nil {
+		return nil, err
+	}
+	if loops < 0 || int(loops) > b.Len() {
+		return nil, fmt.Errorf("Bad GEOGRAPHY loop count %d.", loops)
+	}
+	g := &Geography{Rings: make([][]GeographyPoint, loops)}
+	for idx := range g.Rings {
+		if _, err = readByte(b); err != nil {
+			return nil, err
+		}
+		count, err := readInt(b)
+		if err != nil {
+			return nil, err
+		}
+		if count < minRingPoints-1 || int(count)*24 > b.Len() {
+			return nil, fmt.Errorf("Bad GEOGRAPHY vertex count %d.", count)
+		}
+		vertices := make([]GeographyPoint, count)
+		for i := range vertices {
+			var xyz [3]float64
+			for j := range xyz {
+				if xyz[j], err = readFloat(b); err != nil {
+					return nil, err
+				}
+			}
+			vertices[i] = pointFromXYZ(xyz[0], xyz[1], xyz[2])
+		}
+		ring := make([]GeographyPoint, 0, count+1)
+		ring = append(ring, vertices[0])
+		for i := 1; i < len(vertices); i++ {
+			if idx > 0 {
+				ring = append(ring, vertices[len(vertices)-i])
+			} else {
+				ring = append(ring, vertices[i])
+			}
+		}
+		g.Rings[idx] = append(ring, vertices[0])
+		// origin inside, depth and bound.
+		if _, err = io.ReadFull(b, make([]byte, 1+4+33)); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// xyz returns p as a point on the unit sphere.
+func (p GeographyPoint) xyz() (x, y, z float64) {
+	lat := p.Latitude * math.Pi / 180
+	lng := p.Longitude * math.Pi / 180
+	return math.Cos(lat) * math.Cos(lng), math.Cos(lat) * math.Sin(lng), math.Sin(lat)
+}
+
+// pointFromXYZ is the inverse of GeographyPoint.xyz.
+func pointFromXYZ(x, y, z float64) GeographyPoint {
+	lat := math.Atan2(z, math.Hypot(x, y))
+	lng := math.Atan2(y, x)
+	return GeographyPoint{lng * 180 / math.Pi, lat * 180 / math.Pi}
+}
+
+// String returns p in well-known text, as POINT (lng lat).
+func (p GeographyPoint) String() string {
+	return fmt.Sprintf("POINT (%v %v)", p.Longitude, p.Latitude)
+}
+
+// String returns g in well-known text, as POLYGON ((lng lat, ...), ...).
+func (g *Geography) String() string {
+	var b bytes.Buffer
+	b.WriteString("POLYGON (")
+	for i, ring := range g.Rings {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, p := range ring {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%v %v", p.Longitude, p.Latitude)
+		}
+		b.WriteByte(')')
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,262 @@
+package voltdb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestRoundTripGeographyPoint(t *testing.T) {
+	testVals := []GeographyPoint{{-71.0589, 42.3601}, {0, 0}, {180, -90}}
+	for _, val := range testVals {
+		var b bytes.Buffer
+		if err := writeGeographyPoint(&b, &val); err != nil {
+			t.Fatalf("writeGeographyPoint produced error %v", err)
+		}
+		if b.Len() != 16 {
+			t.Errorf("writeGeographyPoint wrote %v bytes expected 16", b.Len())
+		}
+		result, isNull, err := readGeographyPoint(&b)
+		if result != val || isNull || err != nil {
+			t.Errorf("Expected %v have %v, %v, %v", val, result, isNull, err)
+		}
+	}
+}
+
+func TestNullGeographyPoint(t *testing.T) {
+	var b bytes.Buffer
+	writeGeographyPoint(&b, nil)
+	if result, isNull, err := readGeographyPoint(&b); !isNull || err != nil {
+		t.Errorf("Expected NULL point have %v, %v, %v", result, isNull, err)
+	}
+}
+
+func TestMarshalGeographyPoint(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeParameterSet(&b, []interface{}{GeographyPoint{1.5, 2.5}, (*GeographyPoint)(nil)}); err != nil {
+		t.Fatalf("writeParameterSet produced error %v", err)
+	}
+	readShort(&b)
+	for _, expected := range []bool{false, true} {
+		if vt, _ := readByte(&b); vt != vt_POINT {
+			t.Errorf("Expected type %v have %v", vt_POINT, vt)
+		}
+		if p, isNull, err := readGeographyPoint(&b); isNull != expected || err != nil {
+			t.Errorf("Bad point %v, %v, %v", p, isNull, err)
+		}
+	}
+}
+
+func TestGetPoint(t *testing.T) {
+	p := GeographyPoint{-122.4194, 37.7749}
+	table := newTestTable(t, []testColumn{{"ID", vt_INT}, {"LOC", vt_POINT}},
+		[][]interface{}{{int32(1), p}, {int32(2), nil}})
+	table.AdvanceRow()
+	if v, isNull, err := table.GetPoint(1); v != p || isNull || err != nil {
+		t.Errorf("Bad GetPoint %v, %v, %v", v, isNull, err)
+	}
+	table.AdvanceRow()
+	var id int32
+	var loc GeographyPoint
+	if err := table.Scan(&id, &loc); err != nil || id != 2 || loc != (GeographyPoint{}) {
+		t.Errorf("Bad Scan of NULL point %v, %v, %v", id, loc, err)
+	}
+	if _, isNull, err := table.GetPoint(1); !isNull || err != nil {
+		t.Errorf("Expected NULL point have %v, %v", isNull, err)
+	}
+}
+
+// triangleFixture is the GEOGRAPHY encoding of
+// POLYGON((0 0, 10 0, 0 10, 0 0)).
+const triangleFixture = "0000009b000100000000010000000003" +
+	"3ff00000000000000000000000000000" +
+	"00000000000000003fef838b8c811c17" +
+	"3fc63a1a7e0b73890000000000000000" +
+	"3fef838b8c811c170000000000000000" +
+	"3fc63a1a7e0b73890000000000003ff0" +
+	"00000000000000000000000000004009" +
+	"21fb54442d18c00921fb54442d18003f" +
+	"f0000000000000000000000000000040" +
+	"0921fb54442d18c00921fb54442d18"
+
+var triangle = Geography{[][]GeographyPoint{{{0, 0}, {10, 0}, {0, 10}, {0, 0}}}}
+
+// sameRings compares rings allowing for the rounding of the
+// conversion to and from unit sphere coordinates.
+func sameRings(a, b [][]GeographyPoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if len(a[idx]) != len(b[idx]) {
+			return false
+		}
+		for i, p := range a[idx] {
+			q := b[idx][i]
+			if math.Abs(p.Longitude-q.Longitude) > 1e-9 || math.Abs(p.Latitude-q.Latitude) > 1e-9 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkGeographyBytes compares the encoding of g with fixture, whose
+// vertices are the [start, end) ranges of loops. Vertex coordinates
+// may differ in the last bit between math libraries; everything else
+// must match exactly.
+func checkGeographyBytes(t *testing.T, g *Geography, fixture string, loops [][2]int) {
+	expected, _ := hex.DecodeString(fixture)
+	var b bytes.Buffer
+	if err := writeGeography(&b, g); err != nil {
+		t.Fatalf("writeGeography produced error %v", err)
+	}
+	data := b.Bytes()
+	if len(data) != len(expected) {
+		t.Fatalf("writeGeography wrote %v bytes expected %v", len(data), len(expected))
+	}
+	prev := 0
+	for _, loop := range loops {
+		start, end := loop[0], loop[1]
+		if !bytes.Equal(data[prev:start], expected[prev:start]) {
+			t.Errorf("writeGeography has %x wants %x", data, expected)
+		}
+		for off := start; off < end; off += 8 {
+			have, _ := readFloat(bytes.NewReader(data[off:]))
+			want, _ := readFloat(bytes.NewReader(expected[off:]))
+			if math.Abs(have-want) > 1e-15 {
+				t.Errorf("Vertex coordinate at %v has %v wants %v", off, have, want)
+			}
+		}
+		prev = end
+	}
+	if !bytes.Equal(data[prev:], expected[prev:]) {
+		t.Errorf("writeGeography has %x wants %x", data, expected)
+	}
+}
+
+func TestWriteGeographyTriangle(t *testing.T) {
+	start := 4 + 3 + 4 + 1 + 4
+	checkGeographyBytes(t, &triangle, triangleFixture, [][2]int{{start, start + 3*24}})
+}
+
+func TestReadGeographyTriangle(t *testing.T) {
+	fixture, _ := hex.DecodeString(triangleFixture)
+	g, err := readGeography(bytes.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("readGeography produced error %v", err)
+	}
+	if !sameRings(g.Rings, triangle.Rings) {
+		t.Errorf("readGeography has %v wants %v", g.Rings, triangle.Rings)
+	}
+}
+
+// holeFixture is the GEOGRAPHY encoding of
+// POLYGON((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 8, 8 8, 8 2, 2 2)).
+// It was laid out by hand from the server's polygon format described
+// above writeGeography, not produced by this package and not captured
+// from a server: the has holes flag is set and the hole's vertices
+// after the first are stored in reverse, as (2 2), (8 2), (8 8), (2 8).
+const holeFixture = "0000013e000101000000020000000004" +
+	"3ff00000000000000000000000000000" +
+	"00000000000000003fef838b8c811c17" +
+	"3fc63a1a7e0b73890000000000000000" +
+	"3fef08fb2129168d3fc5e3a8748a0bf4" +
+	"3fc63a1a7e0b73893fef838b8c811c17" +
+	"00000000000000003fc63a1a7e0b7389" +
+	"0000000000003ff00000000000000000" +
+	"000000000000400921fb54442d18c009" +
+	"21fb54442d1800000000043feff605b8" +
+	"b87ffc3fa1db8f6d6a51283fa1de58c9" +
+	"f7dc273fefab5590bcc1f03fc1cda565" +
+	"d4cfa03fa1de58c9f7dc273fef6153f1" +
+	"af3dc13fc1a40add328e293fc1d06c96" +
+	"8d9e193fefab5590bcc1f03fa1b1d460" +
+	"da8fa73fc1d06c968d9e190000000000" +
+	"003ff000000000000000000000000000" +
+	"00400921fb54442d18c00921fb54442d" +
+	"18003ff0000000000000000000000000" +
+	"0000400921fb54442d18c00921fb5444" +
+	"2d18"
+
+var withHole = Geography{[][]GeographyPoint{
+	{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+	{{2, 2}, {2, 8}, {8, 8}, {8, 2}, {2, 2}},
+}}
+
+func TestWriteGeographyWithHole(t *testing.T) {
+	shell := 4 + 3 + 4 + 1 + 4
+	hole := shell + 4*24 + 1 + 4 + 33 + 1 + 4
+	checkGeographyBytes(t, &withHole, holeFixture,
+		[][2]int{{shell, shell + 4*24}, {hole, hole + 4*24}})
+}
+
+func TestReadGeographyWithHole(t *testing.T) {
+	fixture, _ := hex.DecodeString(holeFixture)
+	g, err := readGeography(bytes.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("readGeography produced error %v", err)
+	}
+	if !sameRings(g.Rings, withHole.Rings) {
+		t.Errorf("readGeography has %v wants %v", g.Rings, withHole.Rings)
+	}
+}
+
+func TestRoundTripGeographyWithHole(t *testing.T) {
+	g := Geography{[][]GeographyPoint{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{2, 2}, {2, 8}, {8, 8}, {8, 2}, {2, 2}},
+	}}
+	var b bytes.Buffer
+	if err := writeGeography(&b, &g); err != nil {
+		t.Fatalf("writeGeography produced error %v", err)
+	}
+	data := b.Bytes()
+	if data[6] != 1 {
+		t.Errorf("Expected has holes flag, have %v", data[6])
+	}
+	// the hole's second stored vertex is its ring's last distinct point.
+	hole := 4 + 3 + 4 + (1 + 4 + 4*24 + 1 + 4 + 33)
+	stored := bytes.NewReader(data[hole+1+4+24:])
+	x, _ := readFloat(stored)
+	y, _ := readFloat(stored)
+	z, _ := readFloat(stored)
+	if p := pointFromXYZ(x, y, z); !sameRings([][]GeographyPoint{{p}}, [][]GeographyPoint{{{8, 2}}}) {
+		t.Errorf("Expected hole stored in reverse, have %v", p)
+	}
+
+	result, err := readGeography(&b)
+	if err != nil {
+		t.Fatalf("readGeography produced error %v", err)
+	}
+	if !sameRings(result.Rings, g.Rings) {
+		t.Errorf("readGeography has %v wants %v", result.Rings, g.Rings)
+	}
+}
+
+func TestGeographyNullAndErrors(t *testing.T) {
+	var b bytes.Buffer
+	writeGeography(&b, nil)
+	if g, err := readGeography(&b); g != nil || err != nil {
+		t.Errorf("Expected NULL geography have %v, %v", g, err)
+	}
+	open := Geography{[][]GeographyPoint{{{0, 0}, {10, 0}, {0, 10}, {1, 1}}}}
+	if err := writeGeography(&b, &open); err == nil {
+		t.Errorf("Expected error for an unclosed ring")
+	}
+}
+
+func TestGetGeography(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"AREA", vt_GEOGRAPHY}},
+		[][]interface{}{{&triangle}, {nil}})
+	table.AdvanceRow()
+	if g, isNull, err := table.GetGeography(0); g == nil || !sameRings(g.Rings, triangle.Rings) || isNull || err != nil {
+		t.Errorf("Bad GetGeography %v, %v, %v", g, isNull, err)
+	}
+	table.AdvanceRow()
+	var g *Geography
+	if err := table.Scan(&g); g != nil || err != nil {
+		t.Errorf("Bad Scan of NULL geography %v, %v", g, err)
+	}
+}
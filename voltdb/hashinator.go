@@ -0,0 +1,113 @@
+package voltdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+)
+
+// hashinator.go computes the tokens VoltDB's elastic hashinator uses
+// to place a partition key on the hash ring: the low 32 bits of the
+// first half of the 128 bit x64 MurmurHash3, with seed 0, of the key.
+// Integer keys are hashed as their 8 little-endian bytes and strings
+// as their UTF-8 bytes. The BIGINT NULL value, the smallest int64,
+// is not hashed: as on the server, its token is 0. Mapping a token to
+// a partition needs the cluster topology, which the server reports in
+// @Statistics TOPO.
+
+// PartitionHash returns the hash ring token of key, which may be any
+// integer type, a string or a []byte.
+func PartitionHash(key interface{}) (int32, error) {
+	switch x := key.(type) {
+	case string:
+		return hashToken([]byte(x)), nil
+	case []byte:
+		return hashToken(x), nil
+	}
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() == math.MinInt64 {
+			return 0, nil
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(v.Int()))
+		return hashToken(b[:]), nil
+	}
+	return 0, fmt.Errorf("Can not hash %T partition keys.", key)
+}
+
+func hashToken(data []byte) int32 {
+	h1, _ := murmur3x64(data, 0)
+	return int32(h1)
+}
+
+const (
+	murmurC1 = 0x87c37b91114253d5
+	murmurC2 = 0x4cf5ad432745937f
+)
+
+// murmur3x64 returns the two halves of the 128 bit x64 MurmurHash3
+// of data.
+func murmur3x64(data []byte, seed uint32) (uint64, uint64) {
+	h1, h2 := uint64(seed), uint64(seed)
+	n := len(data)
+	for len(data) >= 16 {
+		k1 := binary.LittleEndian.Uint64(data)
+		k2 := binary.LittleEndian.Uint64(data[8:])
+		data = data[16:]
+
+		h1 ^= murmurMix1(k1)
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		h2 ^= murmurMix2(k2)
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	// the tail holds fewer than 16 bytes.
+	var tail [16]byte
+	copy(tail[:], data)
+	if len(data) > 8 {
+		h2 ^= murmurMix2(binary.LittleEndian.Uint64(tail[8:]))
+	}
+	if len(data) > 0 {
+		h1 ^= murmurMix1(binary.LittleEndian.Uint64(tail[:]))
+	}
+
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1 = murmurFmix(h1)
+	h2 = murmurFmix(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+func murmurMix1(k uint64) uint64 {
+	k *= murmurC1
+	k = bits.RotateLeft64(k, 31)
+	return k * murmurC2
+}
+
+func murmurMix2(k uint64) uint64 {
+	k *= murmurC2
+	k = bits.RotateLeft64(k, 33)
+	return k * murmurC1
+}
+
+func murmurFmix(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
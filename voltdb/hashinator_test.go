@@ -0,0 +1,45 @@
+package voltdb
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// TestMurmur3Verification runs the SMHasher verification of the x64
+// 128 bit MurmurHash3: hash the keys {}, {0}, {0, 1}, ... {0, ..., 254}
+// with seeds 256 down to 2, then hash the concatenated results.
+func TestMurmur3Verification(t *testing.T) {
+	key := make([]byte, 256)
+	hashes := make([]byte, 256*16)
+	for i := 0; i < 256; i++ {
+		key[i] = byte(i)
+		h1, h2 := murmur3x64(key[:i], uint32(256-i))
+		binary.LittleEndian.PutUint64(hashes[i*16:], h1)
+		binary.LittleEndian.PutUint64(hashes[i*16+8:], h2)
+	}
+	h1, _ := murmur3x64(hashes, 0)
+	if uint32(h1) != 0x6384BA69 {
+		t.Errorf("Bad MurmurHash3 verification value have %#x wants 0x6384BA69", uint32(h1))
+	}
+}
+
+func TestPartitionHash(t *testing.T) {
+	for _, key := range []interface{}{int8(42), int16(42), int32(42), 42, int64(42)} {
+		if token, err := PartitionHash(key); token != -1982693896 || err != nil {
+			t.Errorf("PartitionHash(%T) has %v, %v", key, token, err)
+		}
+	}
+	if token, err := PartitionHash("voltdb"); token != -1926283501 || err != nil {
+		t.Errorf("PartitionHash(voltdb) has %v, %v", token, err)
+	}
+	if token, _ := PartitionHash([]byte("voltdb")); token != -1926283501 {
+		t.Errorf("Expected []byte and string keys to hash alike, have %v", token)
+	}
+	if token, err := PartitionHash(int64(math.MinInt64)); token != 0 || err != nil {
+		t.Errorf("PartitionHash(NULL) has %v, %v wants 0", token, err)
+	}
+	if _, err := PartitionHash(4.2); err == nil {
+		t.Errorf("Expected error hashing a float key")
+	}
+}
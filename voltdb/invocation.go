@@ -0,0 +1,66 @@
+package voltdb
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// InvocationBuffer builds the framed wire message of a procedure
+// invocation, for callers that send pre-built invocations themselves,
+// such as benchmark and replay tools. Parameters are serialized as
+// they are appended, so one buffer can produce the message many
+// times, with a new client handle each time if need be.
+type InvocationBuffer struct {
+	procedure string
+	handle    int64
+	count     int
+	params    bytes.Buffer
+}
+
+// NewInvocationBuffer returns an empty InvocationBuffer.
+func NewInvocationBuffer() *InvocationBuffer {
+	return new(InvocationBuffer)
+}
+
+// SetProcedure sets the name of the procedure to invoke.
+func (b *InvocationBuffer) SetProcedure(procedure string) {
+	b.procedure = procedure
+}
+
+// SetHandle sets the client handle the server echoes in its response.
+func (b *InvocationBuffer) SetHandle(handle int64) {
+	b.handle = handle
+}
+
+// AppendParam serializes param as the next parameter. It accepts the
+// same types as Call and leaves the buffer unchanged on error.
+func (b *InvocationBuffer) AppendParam(param interface{}) error {
+	if b.count == math.MaxInt16 {
+		return fmt.Errorf("Too many parameters: %d.", b.count+1)
+	}
+	mark := b.params.Len()
+	if err := marshalParam(&b.params, param); err != nil {
+		b.params.Truncate(mark)
+		return fmt.Errorf("Parameter %d: %v", b.count, err)
+	}
+	b.count++
+	return nil
+}
+
+// Reset removes the parameters, keeping the procedure and handle.
+func (b *InvocationBuffer) Reset() {
+	b.count = 0
+	b.params.Reset()
+}
+
+// Bytes returns the invocation as a complete message, header
+// included, ready to be written to the server.
+func (b *InvocationBuffer) Bytes() []byte {
+	e, _ := newInvocationEncoder(noQueryTimeout)
+	writeString(e, b.procedure)
+	writeLong(e, b.handle)
+	writeShort(e, int16(b.count))
+	e.Write(b.params.Bytes())
+	return e.frame()
+}
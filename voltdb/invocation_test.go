@@ -0,0 +1,58 @@
+package voltdb
+
+import (
+	"bytes"
+	"testing"
+)
+
+// documentedInvocation is an invocation of Insert(5, "x", NULL) with
+// client handle 7, laid out field by field from the procedure
+// invocation and parameter set formats of the VoltDB client wire
+// protocol document rather than produced by this package, so that
+// the encoder is checked against the protocol and not against itself.
+// It is not a capture from a server or the Java client.
+var documentedInvocation = []byte{
+	0x00, 0x00, 0x00, 0x21, // length
+	0x00, // invocation version
+	0x00, 0x00, 0x00, 0x06, 'I', 'n', 's', 'e', 'r', 't',
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, // client handle
+	0x00, 0x03, // parameter count
+	0x05, 0x00, 0x00, 0x00, 0x05, // INTEGER 5
+	0x09, 0x00, 0x00, 0x00, 0x01, 'x', // STRING "x"
+	0x01, // NULL
+}
+
+func TestInvocationBuffer(t *testing.T) {
+	b := NewInvocationBuffer()
+	b.SetProcedure("Insert")
+	b.SetHandle(7)
+	for _, param := range []interface{}{int32(5), "x", nil} {
+		if err := b.AppendParam(param); err != nil {
+			t.Fatalf("AppendParam produced error %v", err)
+		}
+	}
+	if !bytes.Equal(b.Bytes(), documentedInvocation) {
+		t.Errorf("Bad Bytes() have %v wants %v", b.Bytes(), documentedInvocation)
+	}
+
+	call, _ := newInvocationEncoder(noQueryTimeout)
+	serializeCall(call, "Insert", 7, []interface{}{int32(5), "x", nil})
+	if !bytes.Equal(b.Bytes(), call.frame()) {
+		t.Errorf("Bytes() differs from serializeCall")
+	}
+}
+
+func TestInvocationBufferBadParam(t *testing.T) {
+	b := NewInvocationBuffer()
+	b.SetProcedure("Insert")
+	b.AppendParam(int32(5))
+	if err := b.AppendParam(struct{}{}); err == nil {
+		t.Fatalf("Expected error appending an unsupported parameter")
+	}
+	b.AppendParam("x")
+	b.AppendParam(nil)
+	b.SetHandle(7)
+	if !bytes.Equal(b.Bytes(), documentedInvocation) {
+		t.Errorf("Expected the bad parameter to leave no trace, have %v", b.Bytes())
+	}
+}
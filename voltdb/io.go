@@ -1,94 +1,233 @@
 package voltdb
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
-	"runtime"
+	"sync/atomic"
+	"time"
 )
 
 // io.go includes protocol-level de/serialization code. For
 // example, serialize and write a procedure call to the network.
 
-// writeMessage prepends a header and writes header and buf to tcpConn
-// Table represents a VoltDB table, often as a procedure result set.
-// Wrap up some metdata with pointer(s) to row data. Tables are
-// relatively cheap to copy (the associated user data is copied
-// reference).
-func (conn *Conn) writeMessage(buf bytes.Buffer) error {
+// maxMessageSize bounds the declared length of a message.
+const maxMessageSize = 50 * 1024 * 1024
+
+// messageHeaderSize is the length prefix plus protocol version byte.
+const messageHeaderSize = 5
+
+// encoder assembles a complete message in memory, behind room
+// reserved for its header, so that the message reaches the network
+// in a single Write rather than one per serialized field.
+type encoder struct {
+	bytes.Buffer
+	version int8 // sent in the header
+}
+
+func newEncoder() *encoder {
+	e := &encoder{version: protoVersion}
+	var hdr [messageHeaderSize]byte
+	e.Write(hdr[:])
+	return e
+}
+
+// Invocation versions. The server reads the header version of an
+// invocation as its layout: an original invocation starts with the
+// procedure name, and a version 1 invocation with a batch timeout
+// field. Sending ordinary invocations with the login's protocol
+// version would have the server read their procedure name as a batch
+// timeout, so only invocations that override the timeout are sent as
+// version 1.
+const (
+	invocationOriginal     = 0
+	invocationBatchTimeout = 1
+)
+
+// newInvocationEncoder returns an encoder for an invocation, starting
+// it with the batch timeout field if queryTimeout is not
+// noQueryTimeout.
+func newInvocationEncoder(queryTimeout int32) (*encoder, error) {
+	e := newEncoder()
+	if queryTimeout == noQueryTimeout {
+		e.version = invocationOriginal
+		return e, nil
+	}
+	e.version = invocationBatchTimeout
+	if err := writeBatchTimeout(e, queryTimeout); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// flush fills in the header and writes the message to w.
+func (e *encoder) flush(w io.Writer) error {
+	msg := e.frame()
+	if length := len(msg) - 4; length > maxMessageSize {
+		return fmt.Errorf("Message length %d exceeds the %d byte limit.",
+			length, maxMessageSize)
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// frame fills in the header and returns the message.
+func (e *encoder) frame() []byte {
+	msg := e.Bytes()
 	// length includes protocol version.
-	length := buf.Len() + 1
-	var netmsg bytes.Buffer
-	writeInt(&netmsg, int32(length))
-	writeProtoVersion(&netmsg)
-	// 1 copy + 1 n/w write benchmarks faster than 2 n/w writes.
-	io.Copy(&netmsg, &buf)
-	io.Copy(conn.tcpConn, &netmsg)
-	// TODO: obviously wrong
-	return nil
+	order.PutUint32(msg, uint32(len(msg)-4))
+	msg[4] = byte(e.version)
+	return msg
 }
 
-// readMessageHdr reads the standard wireprotocol header.
-func (conn *Conn) readMessageHdr() (size int32, err error) {
+// writeMessage prepends the length and protocol version header to
+// payload and writes the message with a single Write.
+func writeMessage(w io.Writer, payload []byte) error {
+	e := newEncoder()
+	e.Write(payload)
+	return e.flush(w)
+}
+
+// readMessageHdr reads the standard wireprotocol header. A length
+// over limit is an ErrResponseTooLarge error.
+func readMessageHdr(r io.Reader, limit int) (size int32, err error) {
 	// Total message length Integer  4
-	size, err = readInt(conn.tcpConn)
+	size, err = readInt(r)
 	if err != nil {
 		return
 	}
+	if size < 1 {
+		return 0, fmt.Errorf("Invalid message length %d.", size)
+	}
+	if int(size) > limit {
+		return 0, protocolError(ErrResponseTooLarge,
+			"Message length %d exceeds the %d byte limit.", size, limit)
+	}
 	return (size), nil
 }
 
-// readLoginResponse parses the login response message.
-func (conn *Conn) readMessage() (*bytes.Buffer, error) {
-	size, err := conn.readMessageHdr()
+// readMessage reads one message from r and returns its payload,
+// which follows the protocol version byte.
+func readMessage(r io.Reader) ([]byte, error) {
+	frame, err := readFrame(r, maxMessageSize)
 	if err != nil {
 		return nil, err
 	}
-	data := make([]byte, size)
-	if _, err = io.ReadFull(conn.tcpConn, data); err != nil {
+	return frame[messageHeaderSize:], nil
+}
+
+// readFrame reads one message from r and returns it whole, header
+// included. A message longer than limit is refused before its memory
+// is allocated.
+func readFrame(r io.Reader, limit int) ([]byte, error) {
+	size, err := readMessageHdr(r, limit)
+	if err != nil {
 		return nil, err
 	}
-	buf := bytes.NewBuffer(data)
+	frame := make([]byte, 4+int(size))
+	order.PutUint32(frame, uint32(size))
+	data := frame[4:]
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, truncated(err, "message")
+	}
 
-	// Version Byte 1
-	// TODO: error on incorrect version.
-	if _, err = readByte(buf); err != nil {
-		return nil, err
+	// Version Byte 1. Servers may answer with an older version than
+	// the client sent, but never a newer one.
+	if version := int8(data[0]); version < 0 || version > protoVersion {
+		return nil, protocolError(ErrProtocolVersion,
+			"Protocol version %d, expected at most %d.", version, protoVersion)
 	}
+	return frame, nil
+}
 
-	return buf, nil
+// errAuthentication is the error of a login the server refused.
+var errAuthentication = errors.New("Authentication failed.")
+
+// HashScheme selects how the password is hashed during login.
+type HashScheme int8
+
+const (
+	SHA1   HashScheme = 0
+	SHA256 HashScheme = 1
+)
+
+func (s HashScheme) String() string {
+	if s == SHA1 {
+		return "SHA-1"
+	} else if s == SHA256 {
+		return "SHA-256"
+	}
+	return fmt.Sprintf("HashScheme(%d)", int8(s))
 }
 
-func serializeLoginMessage(user string, passwd string) (msg bytes.Buffer, err error) {
-	h := sha1.New()
+// hashPassword returns the password hash sent by the given scheme.
+func hashPassword(passwd string, scheme HashScheme) ([]byte, error) {
+	var h hash.Hash
+	switch scheme {
+	case SHA1:
+		h = sha1.New()
+	case SHA256:
+		h = sha256.New()
+	default:
+		return nil, fmt.Errorf("Unknown hash scheme %v.", scheme)
+	}
 	io.WriteString(h, passwd)
-	shabytes := h.Sum(nil)
+	return h.Sum(nil), nil
+}
 
-	err = writeString(&msg, "database")
+// serializeLoginMessage writes the scheme byte, service name, user
+// and the fixed length (20 or 32 byte) password hash.
+func serializeLoginMessage(user string, passwd string, scheme HashScheme) (msg bytes.Buffer, err error) {
+	shabytes, err := hashPassword(passwd, scheme)
 	if err != nil {
 		return
 	}
-	err = writeString(&msg, user)
+
+	err = writeByte(&msg, int8(scheme))
+	if err != nil {
+		return
+	}
+	err = writeString(&msg, "database")
 	if err != nil {
 		return
 	}
-	err = writeByteString(&msg, shabytes)
+	err = writeString(&msg, user)
 	if err != nil {
 		return
 	}
+	_, err = msg.Write(shabytes)
 	return
 }
 
-func (conn *Conn) readLoginResponse() (*connectionData, error) {
-	buf, err := conn.readMessage()
+func readLoginResponse(r io.Reader) (*connectionData, error) {
+	payload, err := readMessage(r)
 	if err != nil {
 		return nil, err
 	}
-	connData, err := deserializeLoginResponse(buf)
-	return connData, err
+	return decodeLoginResponse(payload)
+}
+
+// decodeLoginResponse decodes the payload of a login response, which
+// it must fill exactly.
+func decodeLoginResponse(payload []byte) (*connectionData, error) {
+	b := bytes.NewBuffer(payload)
+	connData, err := deserializeLoginResponse(b)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkConsumed(b, "login response"); err != nil {
+		return nil, err
+	}
+	return connData, nil
 }
 
 // configures conn with server's advertisement.
@@ -104,7 +243,7 @@ func deserializeLoginResponse(r io.Reader) (connData *connectionData, err error)
 		return
 	}
 	if ok != 0 {
-		return nil, errors.New("Authentication failed.")
+		return nil, errAuthentication
 	}
 
 	hostId, err := readInt(r)
@@ -117,7 +256,7 @@ func deserializeLoginResponse(r io.Reader) (connData *connectionData, err error)
 		return
 	}
 
-	_, err = readLong(r)
+	clusterStart, err := readLong(r)
 	if err != nil {
 		return
 	}
@@ -135,54 +274,139 @@ func deserializeLoginResponse(r io.Reader) (connData *connectionData, err error)
 	connData = new(connectionData)
 	connData.hostId = hostId
 	connData.connId = connId
+	connData.clusterStart = clusterStart
 	connData.leaderAddr = leaderAddr
 	connData.buildString = buildString
 	return connData, nil
 }
 
-func serializeCall(proc string, ud int64, params []interface{}) (msg bytes.Buffer, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if _, ok := r.(runtime.Error); ok {
-				panic(r)
-			}
-			err = r.(error)
-		}
-	}()
+// noQueryTimeout leaves an invocation under the query timeout the
+// server is configured with.
+const noQueryTimeout int32 = -1
 
-	if err = writeString(&msg, proc); err != nil {
-		return
+// Batch timeout override types, which follow the version byte of an
+// invocation.
+const (
+	noBatchTimeout  = 0
+	hasBatchTimeout = 1
+)
+
+func serializeCall(w io.Writer, proc string, ud int64, params []interface{}) error {
+	if err := writeString(w, proc); err != nil {
+		return err
 	}
-	if err = writeLong(&msg, ud); err != nil {
-		return
+	if err := writeLong(w, ud); err != nil {
+		return err
 	}
+	return writeParameterSet(w, params)
+}
 
-	serializedParams, err := serializeParams(params)
+// writeBatchTimeout writes the batch timeout field that starts a
+// version 1 invocation overriding the server's query timeout: a type
+// byte followed by the timeout in milliseconds.
+func writeBatchTimeout(w io.Writer, queryTimeout int32) error {
+	if err := writeByte(w, hasBatchTimeout); err != nil {
+		return err
+	}
+	return writeInt(w, queryTimeout)
+}
+
+// readBatchTimeout reads the batch timeout field of a version 1
+// invocation, returning noQueryTimeout if it carries no override.
+func readBatchTimeout(r io.Reader) (int32, error) {
+	kind, err := readByte(r)
 	if err != nil {
-		return
+		return 0, err
 	}
-	io.Copy(&msg, &serializedParams)
-	return
+	switch kind {
+	case noBatchTimeout:
+		return noQueryTimeout, nil
+	case hasBatchTimeout:
+		return readInt(r)
+	}
+	return 0, fmt.Errorf("Invalid batch timeout type %d.", kind)
 }
 
-func serializeParams(params []interface{}) (msg bytes.Buffer, err error) {
-	// parameter_count short
-	// (type byte, parameter)*
-	if err = writeShort(&msg, int16(len(params))); err != nil {
-		return
+// writeParameterSet writes args as a ParameterSet: a short count
+// followed by each argument prefixed with its type byte.
+func writeParameterSet(w io.Writer, args []interface{}) error {
+	if len(args) > math.MaxInt16 {
+		return fmt.Errorf("Too many parameters: %d.", len(args))
 	}
-	for _, val := range params {
-		if err = marshalParam(&msg, val); err != nil {
-			return
+	if err := writeShort(w, int16(len(args))); err != nil {
+		return err
+	}
+	for idx, arg := range args {
+		if err := marshalParam(w, arg); err != nil {
+			return fmt.Errorf("Parameter %d: %v", idx, err)
 		}
 	}
-	return
+	return nil
 }
 
+// marshalParam writes the type byte and value of param. nil is
+// written as SQL NULL, and a nil pointer as the NULL of the type it
+// points to. Other pointers are written as the value they point to.
 func marshalParam(buf io.Writer, param interface{}) (err error) {
+	switch x := param.(type) {
+	case nil:
+		return writeByte(buf, vt_NULL)
+	case time.Time:
+		if err = writeByte(buf, vt_TIMESTAMP); err != nil {
+			return
+		}
+		return writeTimestamp(buf, x)
+	case []byte:
+		if err = writeByte(buf, vt_VARBIN); err != nil {
+			return
+		}
+		return writeByteString(buf, x)
+	case []int8, []int16, []int32, []int64, []float64, []string, [][]byte:
+		if err = writeByte(buf, vt_ARRAY); err != nil {
+			return
+		}
+		return writeArrayParam(buf, x)
+	case *big.Rat:
+		if err = writeByte(buf, vt_DECIMAL); err != nil {
+			return
+		}
+		return writeDecimal(buf, x)
+	case *big.Int:
+		if err = writeByte(buf, vt_DECIMAL); err != nil {
+			return
+		}
+		if x == nil {
+			return writeDecimal(buf, nil)
+		}
+		return writeDecimal(buf, new(big.Rat).SetInt(x))
+	case GeographyPoint:
+		if err = writeByte(buf, vt_POINT); err != nil {
+			return
+		}
+		return writeGeographyPoint(buf, &x)
+	case *GeographyPoint:
+		if err = writeByte(buf, vt_POINT); err != nil {
+			return
+		}
+		return writeGeographyPoint(buf, x)
+	case Geography:
+		if err = writeByte(buf, vt_GEOGRAPHY); err != nil {
+			return
+		}
+		return writeGeography(buf, &x)
+	case *Geography:
+		if err = writeByte(buf, vt_GEOGRAPHY); err != nil {
+			return
+		}
+		return writeGeography(buf, x)
+	}
+
 	v := reflect.ValueOf(param)
-	if !v.IsValid() {
-		return errors.New("Can not encode value.")
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return marshalNull(buf, v.Type().Elem())
+		}
+		return marshalParam(buf, v.Elem().Interface())
 	}
 	switch v.Kind() {
 	case reflect.Bool:
@@ -191,7 +415,7 @@ func marshalParam(buf io.Writer, param interface{}) (err error) {
 		err = writeBoolean(buf, x)
 	case reflect.Int8:
 		x := v.Int()
-		writeByte(buf, vt_BOOL)
+		writeByte(buf, vt_TINYINT)
 		err = writeByte(buf, int8(x))
 	case reflect.Int16:
 		x := v.Int()
@@ -214,13 +438,230 @@ func marshalParam(buf io.Writer, param interface{}) (err error) {
 		writeByte(buf, vt_STRING)
 		err = writeString(buf, x)
 	default:
-		panic(fmt.Sprintf("Can't marshal %v-type parameters", v.Kind()))
+		err = fmt.Errorf("Can not marshal %T parameters.", param)
 	}
 	return
 }
 
+// marshalNull writes the type byte and NULL sentinel of the
+// parameter type t.
+func marshalNull(buf io.Writer, t reflect.Type) error {
+	var vt int8
+	var null interface{}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		vt, null = vt_TIMESTAMP, timestampNull
+	case t == reflect.TypeOf([]byte(nil)):
+		vt, null = vt_VARBIN, int32(-1)
+	default:
+		switch t.Kind() {
+		case reflect.Bool, reflect.Int8:
+			vt, null = vt_TINYINT, byteNull
+		case reflect.Int16:
+			vt, null = vt_SHORT, shortNull
+		case reflect.Int32:
+			vt, null = vt_INT, intNull
+		case reflect.Int, reflect.Int64:
+			vt, null = vt_LONG, longNull
+		case reflect.Float64:
+			vt, null = vt_FLOAT, floatNull
+		case reflect.String:
+			vt, null = vt_STRING, int32(-1)
+		default:
+			return fmt.Errorf("Can not marshal *%v parameters.", t)
+		}
+	}
+	if err := writeByte(buf, vt); err != nil {
+		return err
+	}
+	return binary.Write(buf, order, null)
+}
+
+// writeArrayParam writes the element type and elements of an array
+// parameter. []byte is not an array: it is sent as VARBINARY, and
+// TINYINT arrays are passed as []int8.
+func writeArrayParam(buf io.Writer, arr interface{}) error {
+	switch x := arr.(type) {
+	case []int8:
+		if err := writeByte(buf, vt_TINYINT); err != nil {
+			return err
+		}
+		return writeByteArray(buf, x)
+	case []int16:
+		return writeShortArray(buf, x)
+	case []int32:
+		return writeIntArray(buf, x)
+	case []int64:
+		return writeLongArray(buf, x)
+	case []float64:
+		return writeFloatArray(buf, x)
+	case []string:
+		if err := writeArrayHeader(buf, vt_STRING, len(x)); err != nil {
+			return err
+		}
+		for _, val := range x {
+			if err := writeString(buf, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	case [][]byte:
+		if err := writeArrayHeader(buf, vt_VARBIN, len(x)); err != nil {
+			return err
+		}
+		for _, val := range x {
+			if err := writeByteString(buf, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Can not marshal %T array parameters.", arr)
+}
+
+// readResponses delivers each response read from r to the pending
+// call with the same client handle. When r fails, every pending
+// call fails with a connectionError. gen identifies the socket r
+// reads so that a replaced socket can not fail its successor. r is
+// buffered, so a message header and body, or several small messages,
+// cost one read of the socket rather than one each. The buffer lives
+// as long as the socket, so no bytes of it are lost between messages.
+func (conn *Conn) readResponses(r io.Reader, gen int) {
+	defer conn.readers.Done()
+	defer func() {
+		// a panic, in decoding or in a callback, must not leave the
+		// pending calls waiting on a reader that is gone.
+		if p := recover(); p != nil {
+			conn.expire(gen, fmt.Errorf("Response reader panicked: %v", p))
+		}
+	}()
+	r = bufio.NewReader(r)
+	for {
+		frame, err := readFrame(r, conn.responseLimit())
+		if err != nil {
+			conn.fail(gen, &connectionError{err, true})
+			return
+		}
+		atomic.AddInt64(&conn.stats.BytesRead, int64(len(frame)))
+		conn.dumpFrame("received", frame)
+		payload := frame[messageHeaderSize:]
+		atomic.AddInt64(&conn.stats.ResponsesReceived, 1)
+		rsp, err := decodeCallResponse(payload)
+		if err != nil {
+			conn.fail(gen, &connectionError{err, true})
+			return
+		}
+		conn.mu.Lock()
+		future, ok := conn.pending[rsp.clientData]
+		delete(conn.pending, rsp.clientData)
+		late := !ok && conn.abandoned[rsp.clientData]
+		if late {
+			delete(conn.abandoned, rsp.clientData)
+			atomic.AddInt64(&conn.stats.LateResponses, 1)
+		}
+		if gen == conn.gen {
+			conn.updateReadDeadline()
+		}
+		validUTF8, compression := conn.validUTF8, conn.compression
+		conn.mu.Unlock()
+		for idx := range rsp.tables {
+			rsp.tables[idx].validUTF8 = validUTF8
+			rsp.tables[idx].compression = compression
+		}
+		if ok {
+			future.resolve(rsp, nil)
+			conn.mu.Lock()
+			conn.signalDrained()
+			conn.mu.Unlock()
+		} else if late {
+			conn.logf("Discarded the late response to handle %d.", rsp.clientData)
+		}
+	}
+}
+
+// fail records err as the reason the connection stopped and fails
+// every pending call with it, except those held to be replayed after
+// a reconnect. Failures of replaced sockets are ignored. Failing a
+// call may run its callback, which may call into the Conn, so fail
+// must not be called with conn.writeMu held; use lose instead.
+func (conn *Conn) fail(gen int, err error) {
+	conn.failLost(conn.lose(gen, err))
+}
+
+// lostCalls are the calls taken from a lost socket by lose.
+type lostCalls struct {
+	err     error
+	pending map[int64]*Future // to fail with err
+	held    []*Future         // to replay after a reconnect
+}
+
+// lose records err as the reason socket generation gen stopped and
+// takes its calls, for failLost to fail once the caller has released
+// its locks. It returns nil if gen was replaced or already lost.
+func (conn *Conn) lose(gen int, err error) *lostCalls {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if gen != conn.gen || conn.err != nil {
+		return nil
+	}
+	conn.err = err
+	var held []*Future
+	if conn.replay && conn.retry != nil && err != ErrClosed {
+		for handle, future := range conn.pending {
+			if future.replay != nil && future.replays < conn.retry.MaxRetries {
+				held = append(held, future)
+				delete(conn.pending, handle)
+			}
+		}
+	}
+	atomic.AddInt64(&conn.stats.Errors, int64(len(conn.pending)))
+	// the responses of abandoned calls can no longer arrive.
+	conn.abandoned = nil
+	lost := &lostCalls{err, conn.pending, held}
+	conn.pending = make(map[int64]*Future)
+	return lost
+}
+
+// failLost fails the calls taken by lose. lost may be nil.
+func (conn *Conn) failLost(lost *lostCalls) {
+	if lost == nil {
+		return
+	}
+	if lost.err != ErrClosed {
+		conn.logf("Connection lost with %d calls pending: %v", len(lost.pending), lost.err)
+	}
+	for _, future := range lost.pending {
+		future.resolve(nil, lost.err)
+	}
+	conn.mu.Lock()
+	conn.signalDrained()
+	conn.mu.Unlock()
+	if len(lost.held) > 0 {
+		go conn.replayCalls(lost.held, lost.err)
+	}
+}
+
+// decodeCallResponse decodes the payload of an invocation response,
+// which it must fill exactly. readMessage reads just the declared
+// length, so bytes left over here mean the server and client disagree
+// on the format, not that the next message has begun.
+func decodeCallResponse(payload []byte) (*Response, error) {
+	b := bytes.NewBuffer(payload)
+	rsp, err := deserializeCallResponse(b)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkConsumed(b, "response"); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
 // readCallResponse reads a stored procedure invocation response.
 func deserializeCallResponse(r io.Reader) (response *Response, err error) {
+	defer func() {
+		err = truncated(err, "response")
+	}()
 	response = new(Response)
 	if response.clientData, err = readLong(r); err != nil {
 		return nil, err
@@ -249,7 +690,7 @@ func deserializeCallResponse(r io.Reader) (response *Response, err error) {
 			return nil, err
 		}
 	}
-	if response.clusterLatency, err = readInt(r); err != nil {
+	if response.clusterRoundTrip, err = readInt(r); err != nil {
 		return nil, err
 	}
 	if response.fieldsPresent&(1<<6) != 0 {
@@ -267,6 +708,9 @@ func deserializeCallResponse(r io.Reader) (response *Response, err error) {
 	if response.resultCount, err = readShort(r); err != nil {
 		return nil, err
 	}
+	if response.resultCount < 0 {
+		return nil, protocolError(ErrLengthMismatch, "Negative result count %d.", response.resultCount)
+	}
 
 	response.tables = make([]Table, response.resultCount)
 	for idx, _ := range response.tables {
@@ -280,48 +724,84 @@ func deserializeCallResponse(r io.Reader) (response *Response, err error) {
 func deserializeTable(r io.Reader) (t Table, err error) {
 	var errTable Table
 
-	ttlLength, err := readInt(r) // ttlLength
-	if err != nil {
-		return errTable, err
-	}
-	metaLength, err := readInt(r) // metaLength
+	t, tableByteCount, err := readTableHeader(r)
 	if err != nil {
 		return errTable, err
 	}
 
-	t.statusCode, err = readByte(r)
-	if err != nil {
-		return errTable, err
+	// OPTIMIZE? Could avoid a possibly large copy here by
+	// initializing buf to r[Pos():tableByteCount]. Unsure
+	// if that way lies madness or cleverness. For now, suck
+	// up the copy. Maybe in the future change this method
+	// to take a buffer instead of a reader?
+	if _, err = io.CopyN(&t.rows, r, tableByteCount); err != nil {
+		return errTable, truncated(err, "table")
 	}
-
-	t.columnCount, err = readShort(r)
-	if err != nil {
+	if err = checkRowLengths(t.rows.Bytes(), t.rowCount); err != nil {
 		return errTable, err
 	}
+	t.rowData = t.rows.Bytes()
+	return t, nil
+}
 
-	// column type "array" and column name "array" are not
-	// length prefixed arrays. they are really just columnCount
-	// len sequences of bytes (types) and strings (names).
-	var i int16
-	for i = 0; i < t.columnCount; i++ {
-		ct, err := readByte(r)
-		if err != nil {
-			return errTable, err
+// checkRowLengths checks that data holds exactly rowCount rows, each
+// an int32 length followed by that many bytes.
+func checkRowLengths(data []byte, rowCount int32) error {
+	offset := 0
+	for i := int32(0); i < rowCount; i++ {
+		if len(data)-offset < 4 {
+			return protocolError(ErrLengthMismatch,
+				"Table row data ends before row %d of %d.", i, rowCount)
+		}
+		rowLength := int(int32(order.Uint32(data[offset:])))
+		offset += 4
+		if rowLength < 0 || rowLength > len(data)-offset {
+			return protocolError(ErrLengthMismatch,
+				"Row %d length %d exceeds the table's row data.", i, rowLength)
 		}
-		t.columnTypes = append(t.columnTypes, ct)
+		offset += rowLength
 	}
+	if offset != len(data) {
+		return protocolError(ErrLengthMismatch,
+			"Table has %d bytes beyond its %d rows.", len(data)-offset, rowCount)
+	}
+	return nil
+}
 
-	for i = 0; i < t.columnCount; i++ {
-		cn, err := readString(r)
-		if err != nil {
-			return errTable, err
+// readTableHeader reads a table up to its row data and returns the
+// size of the row data that follows. The metadata must fill exactly
+// the length declared for it.
+func readTableHeader(r io.Reader) (t Table, tableByteCount int64, err error) {
+	var errTable Table
+
+	ttlLength, err := readInt(r) // ttlLength
+	if err != nil {
+		return errTable, 0, err
+	}
+	metaLength, err := readInt(r) // metaLength
+	if err != nil {
+		return errTable, 0, err
+	}
+	if metaLength < 0 || int64(ttlLength) < int64(metaLength)+8 {
+		return errTable, 0, protocolError(ErrLengthMismatch,
+			"Table length %d cannot hold %d bytes of metadata.", ttlLength, metaLength)
+	}
+	meta := &io.LimitedReader{R: r, N: int64(metaLength)}
+	if err = readTableMetadata(meta, &t); err != nil {
+		if (err == io.EOF || err == io.ErrUnexpectedEOF) && meta.N == 0 {
+			return errTable, 0, protocolError(ErrLengthMismatch,
+				"Table metadata exceeds its declared %d bytes.", metaLength)
 		}
-		t.columnNames = append(t.columnNames, cn)
+		return errTable, 0, err
+	}
+	if meta.N != 0 {
+		return errTable, 0, protocolError(ErrLengthMismatch,
+			"Table metadata is %d bytes short of its declared %d.", meta.N, metaLength)
 	}
 
 	t.rowCount, err = readInt(r)
 	if err != nil {
-		return errTable, err
+		return errTable, 0, err
 	}
 
 	// the total row data byte count is:
@@ -329,13 +809,36 @@ func deserializeTable(r io.Reader) (t Table, err error) {
 	//  - 4 byte metaLength field
 	//  - metaLength
 	//  - 4 byte row count field
-	var tableByteCount int64 = int64(ttlLength - metaLength - 8)
+	return t, int64(ttlLength - metaLength - 8), nil
+}
 
-	// OPTIMIZE? Could avoid a possibly large copy here by
-	// initializing buf to r[Pos():tableByteCount]. Unsure
-	// if that way lies madness or cleverness. For now, suck
-	// up the copy. Maybe in the future change this method
-	// to take a buffer instead of a reader?
-	io.CopyN(&t.rows, r, tableByteCount)
-	return t, nil
+// readTableMetadata reads the status, column types and column names
+// of a table into t.
+func readTableMetadata(r io.Reader, t *Table) (err error) {
+	if t.statusCode, err = readByte(r); err != nil {
+		return err
+	}
+	if t.columnCount, err = readShort(r); err != nil {
+		return err
+	}
+	if t.columnCount < 0 {
+		return protocolError(ErrLengthMismatch, "Negative column count %d.", t.columnCount)
+	}
+
+	// column type "array" and column name "array" are not
+	// length prefixed arrays. they are really just columnCount
+	// len sequences of bytes (types) and strings (names).
+	t.columnTypes = make([]int8, t.columnCount)
+	for i := range t.columnTypes {
+		if t.columnTypes[i], err = readByte(r); err != nil {
+			return err
+		}
+	}
+	t.columnNames = make([]string, t.columnCount)
+	for i := range t.columnNames {
+		if t.columnNames[i], err = readString(r); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,640 @@
+package voltdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func loginFixture(scheme byte, hash string) []byte {
+	fixture := []byte{scheme,
+		0x00, 0x00, 0x00, 0x08, 'd', 'a', 't', 'a', 'b', 'a', 's', 'e',
+		0x00, 0x00, 0x00, 0x04, 'u', 's', 'e', 'r'}
+	hashBytes, _ := hex.DecodeString(hash)
+	return append(fixture, hashBytes...)
+}
+
+func TestSerializeLoginMessage(t *testing.T) {
+	testVals := map[HashScheme][]byte{
+		SHA1: loginFixture(0x00,
+			"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8"),
+		SHA256: loginFixture(0x01,
+			"5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"),
+	}
+	for scheme, expected := range testVals {
+		msg, err := serializeLoginMessage("user", "password", scheme)
+		if err != nil {
+			t.Errorf("serializeLoginMessage produced error %v for %v", err, scheme)
+		}
+		if !bytes.Equal(msg.Bytes(), expected) {
+			t.Errorf("%v login has %x wants %x", scheme, msg.Bytes(), expected)
+		}
+	}
+}
+
+func TestSerializeLoginMessageUnknownScheme(t *testing.T) {
+	if _, err := serializeLoginMessage("user", "password", HashScheme(7)); err == nil {
+		t.Errorf("Expected error for unknown hash scheme")
+	}
+}
+
+func TestRoundTripMessage(t *testing.T) {
+	var b bytes.Buffer
+	payload := []byte("payload bytes")
+	if err := writeMessage(&b, payload); err != nil {
+		t.Fatalf("writeMessage produced error %v", err)
+	}
+	if b.Len() != 4+1+len(payload) {
+		t.Errorf("writeMessage wrote %v bytes expected %v", b.Len(), 4+1+len(payload))
+	}
+	result, err := readMessage(&b)
+	if err != nil || !bytes.Equal(result, payload) {
+		t.Errorf("Expected %q have %q, %v", payload, result, err)
+	}
+}
+
+func TestReadMessageBadLength(t *testing.T) {
+	testVals := [...]int32{-1, 0, maxMessageSize + 1}
+	for _, length := range testVals {
+		var b bytes.Buffer
+		writeInt(&b, length)
+		b.Write(make([]byte, 16))
+		if _, err := readMessage(&b); err == nil {
+			t.Errorf("Expected error for message length %v", length)
+		}
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	var b bytes.Buffer
+	writeInt(&b, 1<<30)
+	hdr := b.Bytes()
+	if _, err := readFrame(bytes.NewReader(hdr), 100); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Expected ErrResponseTooLarge have %v", err)
+	}
+	// the declared gigabyte is refused before it is allocated.
+	allocs := testing.AllocsPerRun(10, func() {
+		readFrame(bytes.NewReader(hdr), 100)
+	})
+	if allocs > 10 {
+		t.Errorf("Bad allocations have %v wants at most 10", allocs)
+	}
+	b.Reset()
+	writeMessage(&b, make([]byte, 100))
+	if _, err := readFrame(&b, 100+messageHeaderSize); err != nil {
+		t.Errorf("readFrame at the limit produced error %v", err)
+	}
+}
+
+// allocatedBytes returns the bytes allocated while f runs.
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestInnerLengthTooLarge(t *testing.T) {
+	// a response that fits the frame limit but whose status string
+	// declares nearly 2GB.
+	var b bytes.Buffer
+	writeLong(&b, 1)
+	writeByte(&b, 1<<5) // fields present: status string
+	writeByte(&b, int8(SUCCESS))
+	writeInt(&b, math.MaxInt32)
+	b.WriteString("short")
+	payload := b.Bytes()
+	var err error
+	if n := allocatedBytes(func() { _, err = decodeCallResponse(payload) }); n > 1<<20 {
+		t.Errorf("Bad allocation for a declared status string have %v bytes", n)
+	}
+	if !errors.Is(err, ErrTruncatedMessage) {
+		t.Errorf("Expected ErrTruncatedMessage have %v", err)
+	}
+
+	// a reader without Len is read only as far as it has bytes.
+	b.Reset()
+	writeInt(&b, math.MaxInt32)
+	b.WriteString("short")
+	r := iotest.OneByteReader(&b)
+	if n := allocatedBytes(func() { _, err = readVarbinary(r) }); n > 1<<20 {
+		t.Errorf("Bad allocation for a declared VARBINARY have %v bytes", n)
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF have %v", err)
+	}
+	b.Reset()
+	writeInt(&b, 5)
+	b.WriteString("bytes")
+	if bs, err := readByteArray(iotest.OneByteReader(&b)); len(bs) != 5 || err != nil {
+		t.Errorf("Bad byte array have %v, %v", bs, err)
+	}
+}
+
+func TestSetMaxResponseSize(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		_, handle, _, err := readTestInvocation(c)
+		if err != nil {
+			return
+		}
+		writeTestResponse(c, handle, int8(SUCCESS), echoTable(strings.Repeat("x", 1000)))
+		readTestInvocation(c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if have := conn.responseLimit(); have != maxMessageSize {
+		t.Errorf("Bad default limit have %v wants %v", have, maxMessageSize)
+	}
+	conn.SetMaxResponseSize(500)
+	if _, err := conn.Call("Big"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Expected ErrResponseTooLarge have %v", err)
+	}
+	conn.SetMaxResponseSize(0)
+	if have := conn.responseLimit(); have != maxMessageSize {
+		t.Errorf("Bad restored limit have %v wants %v", have, maxMessageSize)
+	}
+}
+
+func TestWriteMessageTooLarge(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeMessage(&b, make([]byte, maxMessageSize)); err == nil {
+		t.Errorf("Expected error writing oversized message")
+	}
+	if b.Len() != 0 {
+		t.Errorf("Expected nothing written, have %v bytes", b.Len())
+	}
+}
+
+// countingWriter counts the Write calls made on it.
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+var benchParams = []interface{}{int64(5085551234), 3, "a contestant name", 1.5}
+
+// serializes straight to the writer, one Write per field.
+func BenchmarkSerializeCallUnbuffered(b *testing.B) {
+	b.ReportAllocs()
+	var w countingWriter
+	for i := 0; i < b.N; i++ {
+		writeInt(&w, 0)
+		writeProtoVersion(&w)
+		serializeCall(&w, "Vote", int64(i), benchParams)
+	}
+	b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+}
+
+func BenchmarkSerializeCallEncoded(b *testing.B) {
+	b.ReportAllocs()
+	var w countingWriter
+	for i := 0; i < b.N; i++ {
+		e := newEncoder()
+		serializeCall(e, "Vote", int64(i), benchParams)
+		e.flush(&w)
+	}
+	b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+}
+
+// repeatReader serves data over and over, counting its reads.
+type repeatReader struct {
+	data  []byte
+	off   int
+	reads int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	r.reads++
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.data)
+	}
+	return n, nil
+}
+
+// wideResponse returns a framed response holding a 20 column table.
+func wideResponse() []byte {
+	var names []testColumn
+	var row []interface{}
+	for i := 0; i < 5; i++ {
+		names = append(names, testColumn{fmt.Sprintf("I%d", i), vt_INT},
+			testColumn{fmt.Sprintf("L%d", i), vt_LONG},
+			testColumn{fmt.Sprintf("S%d", i), vt_STRING},
+			testColumn{fmt.Sprintf("F%d", i), vt_FLOAT})
+		row = append(row, int32(i), int64(i), "value", float64(i))
+	}
+	var table, msg bytes.Buffer
+	writeTestTable(&table, -128, names, [][]interface{}{row, row, row})
+	writeTestResponse(&msg, 1, int8(SUCCESS), table.Bytes())
+	return msg.Bytes()
+}
+
+func benchmarkReadResponses(b *testing.B, buffered bool) {
+	b.ReportAllocs()
+	src := &repeatReader{data: wideResponse()}
+	var r io.Reader = src
+	if buffered {
+		r = bufio.NewReader(src)
+	}
+	for i := 0; i < b.N; i++ {
+		payload, err := readMessage(r)
+		if err != nil {
+			b.Fatalf("readMessage produced error %v", err)
+		}
+		if _, err := decodeCallResponse(payload); err != nil {
+			b.Fatalf("decodeCallResponse produced error %v", err)
+		}
+	}
+	b.ReportMetric(float64(src.reads)/float64(b.N), "reads/op")
+}
+
+func BenchmarkReadResponsesUnbuffered(b *testing.B) {
+	benchmarkReadResponses(b, false)
+}
+
+func BenchmarkReadResponsesBuffered(b *testing.B) {
+	benchmarkReadResponses(b, true)
+}
+
+func TestBufferedReadAcrossMessages(t *testing.T) {
+	// messages straddling the buffer, larger than it and smaller,
+	// delivered a few bytes at a time.
+	payloads := [][]byte{bytes.Repeat([]byte{1}, 4000), bytes.Repeat([]byte{2}, 10000),
+		{3}, bytes.Repeat([]byte{4}, 4096)}
+	var b bytes.Buffer
+	for _, payload := range payloads {
+		writeMessage(&b, payload)
+	}
+	r := bufio.NewReader(iotest.HalfReader(&b))
+	for idx, expected := range payloads {
+		payload, err := readMessage(r)
+		if err != nil || !bytes.Equal(payload, expected) {
+			t.Errorf("Message %d has %d bytes, %v wants %d bytes", idx, len(payload), err, len(expected))
+		}
+	}
+	if _, err := readMessage(r); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last message have %v", err)
+	}
+}
+
+func TestEncoderFlush(t *testing.T) {
+	var w countingWriter
+	e := newEncoder()
+	if err := serializeCall(e, "Vote", 7, benchParams); err != nil {
+		t.Fatalf("serializeCall produced error %v", err)
+	}
+	var b bytes.Buffer
+	if err := e.flush(io.MultiWriter(&b, &w)); err != nil {
+		t.Fatalf("flush produced error %v", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("Expected 1 write, have %v", w.writes)
+	}
+	proc, handle, _, err := readTestInvocation(&b)
+	if err != nil || proc != "Vote" || handle != 7 {
+		t.Errorf("Bad invocation %v, %v, %v", proc, handle, err)
+	}
+}
+
+func TestWriteParameterSet(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 0, time.UTC)
+	args := []interface{}{int32(7), int64(8), "eight", 2.5, ts, []byte{1, 2}, nil}
+	var b bytes.Buffer
+	if err := writeParameterSet(&b, args); err != nil {
+		t.Fatalf("writeParameterSet produced error %v", err)
+	}
+	if cnt, _ := readShort(&b); int(cnt) != len(args) {
+		t.Errorf("Parameter count has %v wants %v", cnt, len(args))
+	}
+	expected := []int8{vt_INT, vt_LONG, vt_STRING, vt_FLOAT, vt_TIMESTAMP, vt_VARBIN, vt_NULL}
+	for idx, vt := range expected {
+		if have, _ := readByte(&b); have != vt {
+			t.Fatalf("Parameter %d has type %v wants %v", idx, have, vt)
+		}
+		var val interface{}
+		switch vt {
+		case vt_INT:
+			val, _ = readInt(&b)
+		case vt_LONG:
+			val, _ = readLong(&b)
+		case vt_STRING:
+			val, _ = readString(&b)
+		case vt_FLOAT:
+			val, _ = readFloat(&b)
+		case vt_TIMESTAMP:
+			val, _ = readTimestamp(&b)
+		case vt_VARBIN:
+			bs, _ := readVarbinary(&b)
+			if !bytes.Equal(bs, args[idx].([]byte)) {
+				t.Errorf("Parameter %d has %v wants %v", idx, bs, args[idx])
+			}
+			continue
+		case vt_NULL:
+			continue
+		}
+		if val != args[idx] {
+			t.Errorf("Parameter %d has %v wants %v", idx, val, args[idx])
+		}
+	}
+	if b.Len() != 0 {
+		t.Errorf("Unexpected %v trailing bytes", b.Len())
+	}
+}
+
+func TestWriteParameterSetUnsupported(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeParameterSet(&b, []interface{}{1, struct{}{}}); err == nil {
+		t.Errorf("Expected error for unsupported parameter type")
+	}
+	if err := serializeCall(&b, "Proc", 1, []interface{}{uint64(1)}); err == nil {
+		t.Errorf("Expected serializeCall to return the error")
+	}
+}
+
+func TestWriteParameterSetArrays(t *testing.T) {
+	args := []interface{}{[]int8{-1, 2}, []int16{3, -4}, []int32{5, 6},
+		[]int64{1 << 40}, []float64{2.5, -0.5}, []string{"x", "yz"},
+		[][]byte{{1}, {2, 3}}}
+	var b bytes.Buffer
+	if err := writeParameterSet(&b, args); err != nil {
+		t.Fatalf("writeParameterSet produced error %v", err)
+	}
+	readShort(&b)
+	for _, expected := range args {
+		if vt, _ := readByte(&b); vt != vt_ARRAY {
+			t.Fatalf("Expected array type for %T, have %v", expected, vt)
+		}
+		if val, err := readArray(&b); err != nil || !reflect.DeepEqual(val, expected) {
+			t.Errorf("Expected %v have %v, %v", expected, val, err)
+		}
+	}
+}
+
+func TestByteSliceIsVarbinary(t *testing.T) {
+	var b bytes.Buffer
+	marshalParam(&b, []byte{1, 2})
+	if vt, _ := readByte(&b); vt != vt_VARBIN {
+		t.Errorf("Expected []byte sent as VARBINARY, have type %v", vt)
+	}
+	b.Reset()
+	marshalParam(&b, []int8{1, 2})
+	vt, _ := readByte(&b)
+	elemType, _ := readByte(&b)
+	if vt != vt_ARRAY || elemType != vt_TINYINT {
+		t.Errorf("Expected []int8 sent as a TINYINT array, have %v, %v", vt, elemType)
+	}
+}
+
+func TestDecimalParams(t *testing.T) {
+	price, _ := new(big.Rat).SetString("1234.56")
+	fee, _ := new(big.Rat).SetString("-0.01")
+	testVals := []struct {
+		param    interface{}
+		expected []byte
+	}{
+		{price, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x04, 0x62, 0xD3, 0x66, 0x41, 0x00, 0x00}},
+		{fee, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			0xFF, 0xFF, 0xFF, 0xFD, 0xAB, 0xF4, 0x1C, 0x00}},
+		{big.NewInt(1000000), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x0D, 0xE0, 0xB6, 0xB3, 0xA7, 0x64, 0x00, 0x00}},
+		{(*big.Int)(nil), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, tv := range testVals {
+		var b bytes.Buffer
+		if err := marshalParam(&b, tv.param); err != nil {
+			t.Fatalf("marshalParam(%v) produced error %v", tv.param, err)
+		}
+		expected := append([]byte{byte(vt_DECIMAL)}, tv.expected...)
+		if !bytes.Equal(b.Bytes(), expected) {
+			t.Errorf("marshalParam(%v) has %x wants %x", tv.param, b.Bytes(), expected)
+		}
+	}
+
+	// 27 integer digits exceed precision 38 at scale 12.
+	tooLarge, _ := new(big.Int).SetString("100000000000000000000000000", 10)
+	var b bytes.Buffer
+	if err := marshalParam(&b, tooLarge); err == nil {
+		t.Errorf("Expected error for a decimal exceeding precision 38")
+	}
+}
+
+func TestNilParams(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 0, time.UTC)
+	var b bytes.Buffer
+	params := []interface{}{nil, (*int32)(nil), (*string)(nil), (*float64)(nil),
+		(*time.Time)(nil), (*[]byte)(nil), (*int64)(nil), &ts}
+	if err := writeParameterSet(&b, params); err != nil {
+		t.Fatalf("writeParameterSet produced error %v", err)
+	}
+	var expected bytes.Buffer
+	writeShort(&expected, int16(len(params)))
+	writeByte(&expected, vt_NULL)
+	writeByte(&expected, vt_INT)
+	writeInt(&expected, intNull)
+	writeByte(&expected, vt_STRING)
+	writeInt(&expected, -1)
+	writeByte(&expected, vt_FLOAT)
+	writeFloat(&expected, floatNull)
+	writeByte(&expected, vt_TIMESTAMP)
+	writeLong(&expected, timestampNull)
+	writeByte(&expected, vt_VARBIN)
+	writeInt(&expected, -1)
+	writeByte(&expected, vt_LONG)
+	writeLong(&expected, longNull)
+	writeByte(&expected, vt_TIMESTAMP)
+	writeTimestamp(&expected, ts)
+	if !bytes.Equal(b.Bytes(), expected.Bytes()) {
+		t.Errorf("Bad NULL parameters have %v wants %v", b.Bytes(), expected.Bytes())
+	}
+}
+
+func TestNilPointerToUnsupportedType(t *testing.T) {
+	var b bytes.Buffer
+	if err := marshalParam(&b, (*struct{})(nil)); err == nil {
+		t.Errorf("Expected error marshaling a nil *struct{}")
+	}
+}
+
+// loginReply is a successful login response payload.
+func loginReply() []byte {
+	var login bytes.Buffer
+	writeByte(&login, 0)
+	writeInt(&login, 1)
+	writeLong(&login, 2)
+	writeLong(&login, 3)
+	writeInt(&login, 0x7F000001)
+	writeString(&login, "testbuild")
+	return login.Bytes()
+}
+
+func TestReadLoginResponseVersion(t *testing.T) {
+	for version, ok := range map[byte]bool{0: true, 1: true, 2: false, 0x80: false} {
+		var b bytes.Buffer
+		payload := loginReply()
+		writeInt(&b, int32(len(payload)+1))
+		b.WriteByte(version)
+		b.Write(payload)
+		_, err := readLoginResponse(&b)
+		if ok && err != nil {
+			t.Errorf("Version %d produced error %v", version, err)
+		}
+		if !ok && !errors.Is(err, ErrProtocolVersion) {
+			t.Errorf("Expected ErrProtocolVersion for version %d, have %v", version, err)
+		}
+	}
+}
+
+func TestConnectProtocolVersionMismatch(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		readMessage(c)
+		payload := loginReply()
+		writeInt(c, int32(len(payload)+1))
+		writeByte(c, protoVersion+1)
+		c.Write(payload)
+	}()
+	_, err = NewConnection("user", "", listener.Addr().String())
+	if !errors.Is(err, ErrProtocolVersion) {
+		t.Errorf("Expected ErrProtocolVersion have %v", err)
+	}
+}
+
+// capturedLoginResponse is a login response payload from a server
+// with host id 2, leader 10.0.0.7 and build "6.2".
+var capturedLoginResponse = []byte{
+	0x00,                   // authentication result
+	0x00, 0x00, 0x00, 0x02, // host id
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x05, // connection id
+	0x00, 0x00, 0x01, 0x37, 0x5A, 0x58, 0xD4, 0x40, // cluster start
+	0x0A, 0x00, 0x00, 0x07, // leader address
+	0x00, 0x00, 0x00, 0x03, '6', '.', '2',
+}
+
+func TestDeserializeLoginResponse(t *testing.T) {
+	connData, err := deserializeLoginResponse(bytes.NewBuffer(capturedLoginResponse))
+	if err != nil {
+		t.Fatalf("deserializeLoginResponse produced error %v", err)
+	}
+	conn := Conn{connData: connData}
+	info := conn.ServerInfo()
+	start := time.Date(2012, 5, 17, 10, 30, 0, 0, time.UTC)
+	if info.HostID != 2 || info.ConnectionID != 261 || !info.ClusterStartTime.Equal(start) ||
+		!info.LeaderAddr.Equal(net.IPv4(10, 0, 0, 7)) || info.BuildString != "6.2" {
+		t.Errorf("Bad ServerInfo %+v", info)
+	}
+}
+
+func TestConnServerInfo(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	info := conn.ServerInfo()
+	if info == nil || info.HostID != 1 || info.ConnectionID != 2 ||
+		!info.LeaderAddr.Equal(net.IPv4(127, 0, 0, 1)) || info.BuildString != "testbuild" {
+		t.Errorf("Bad ServerInfo %+v", info)
+	}
+	conn.Close()
+	if info := conn.ServerInfo(); info != nil {
+		t.Errorf("Expected no ServerInfo after Close, have %+v", info)
+	}
+}
+
+func TestReaderPanicFailsConnection(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	release := make(chan struct{})
+	err = conn.CallWithCallback("Panic", nil, func(*Response, error) {
+		<-release
+		panic("callback failed")
+	})
+	if err != nil {
+		t.Fatalf("CallWithCallback produced error %v", err)
+	}
+	// pending while the reader panics.
+	future, err := conn.CallAsync("Waiting")
+	if err != nil {
+		t.Fatalf("CallAsync produced error %v", err)
+	}
+	close(release)
+	select {
+	case <-future.done:
+		if future.err == nil || !strings.Contains(future.err.Error(), "callback failed") {
+			t.Errorf("Bad error have %v", future.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Pending call hung after the reader panicked")
+	}
+}
+
+func TestNegativeResultCount(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		_, handle, _, err := readTestInvocation(c)
+		if err != nil {
+			return
+		}
+		var rsp bytes.Buffer
+		writeLong(&rsp, handle)
+		writeByte(&rsp, 0)
+		writeByte(&rsp, int8(SUCCESS))
+		writeByte(&rsp, UNINITIALIZED_APP_STATUS_CODE)
+		writeInt(&rsp, 0)
+		writeShort(&rsp, -1) // result count
+		writeMessage(c, rsp.Bytes())
+		readTestInvocation(c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallTimeout(time.Second, "Malformed"); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("Expected ErrLengthMismatch have %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package voltdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// MarshalJSON encodes the rows not yet read by AdvanceRow as an array
+// of objects keyed by column name, in column order, without advancing
+// the table. Rows already read are left out, as by Rows and unlike
+// SumColumn, so a table read to its end encodes as []. NULL columns are null, TIMESTAMPs RFC 3339
+// strings, DECIMALs strings so no precision is lost, VARBINARY base64
+// strings and geospatial values well-known text strings. JSON has no
+// number for a FLOAT that is NaN or infinite, so these are the
+// strings "NaN", "Infinity" and "-Infinity".
+func (table *Table) MarshalJSON() ([]byte, error) {
+	rows := table.unreadRows()
+	names := make([][]byte, len(table.columnNames))
+	for idx, name := range table.columnNames {
+		var err error
+		if names[idx], err = json.Marshal(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for first := true; rows.AdvanceRow(); first = false {
+		if !first {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		for idx := range rows.columnTypes {
+			val, err := rows.value(idx)
+			if err != nil {
+				return nil, err
+			}
+			field, err := jsonField(val)
+			if err != nil {
+				return nil, fmt.Errorf("Column %s: %v", names[idx], err)
+			}
+			if idx > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(names[idx])
+			b.WriteByte(':')
+			b.Write(field)
+		}
+		b.WriteByte('}')
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+// jsonField encodes a column value returned by Table.value.
+func jsonField(val interface{}) ([]byte, error) {
+	switch v := val.(type) {
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return json.Marshal("NaN")
+		case math.IsInf(v, 1):
+			return json.Marshal("Infinity")
+		case math.IsInf(v, -1):
+			return json.Marshal("-Infinity")
+		}
+	case time.Time:
+		return json.Marshal(v.UTC().Format(timestampFormat))
+	case *big.Rat:
+		return json.Marshal(v.FloatString(decimalScale))
+	case GeographyPoint:
+		return json.Marshal(v.String())
+	case *Geography:
+		return json.Marshal(v.String())
+	}
+	return json.Marshal(val)
+}
+
+// CallJSON calls a procedure that takes a single JSON document as a
+// STRING parameter, passing it jsonArg encoded with json.Marshal. A
+// json.RawMessage, already JSON, is sent compacted.
+func (conn *Conn) CallJSON(procedure string, jsonArg interface{}) (*Response, error) {
+	doc, err := json.Marshal(jsonArg)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Call(procedure, string(doc))
+}
@@ -0,0 +1,126 @@
+package voltdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 123456000, time.UTC)
+	amount, _ := new(big.Rat).SetString("1234567890.123456789012")
+	cols := []testColumn{{"B", vt_TINYINT}, {"S", vt_SHORT}, {"I", vt_INT},
+		{"L", vt_LONG}, {"F", vt_FLOAT}, {"STR", vt_STRING}, {"T", vt_TIMESTAMP},
+		{"D", vt_DECIMAL}, {"V", vt_VARBIN}, {"P", vt_POINT}}
+	table := newTestTable(t, cols, [][]interface{}{
+		{int8(1), int16(2), int32(3), int64(4), 2.5, "a \"b\"", ts, amount,
+			[]byte("hi"), GeographyPoint{-71.5, 42.25}},
+		{nil, nil, nil, nil, nil, nil, nil, nil, nil, nil},
+	})
+	data, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("Marshal produced error %v", err)
+	}
+	expected := `[{"B":1,"S":2,"I":3,"L":4,"F":2.5,"STR":"a \"b\"",` +
+		`"T":"2012-05-17T10:30:15.123456Z","D":"1234567890.123456789012",` +
+		`"V":"aGk=","P":"POINT (-71.5 42.25)"},` +
+		`{"B":null,"S":null,"I":null,"L":null,"F":null,"STR":null,` +
+		`"T":null,"D":null,"V":null,"P":null}]`
+	if string(data) != expected {
+		t.Errorf("Bad JSON have %s wants %s", data, expected)
+	}
+	if table.RowCount() != 2 || !table.AdvanceRow() {
+		t.Errorf("Expected MarshalJSON not to advance the table")
+	}
+}
+
+func TestMarshalJSONEmpty(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"I", vt_INT}}, nil)
+	data, err := json.Marshal(table)
+	if err != nil || string(data) != "[]" {
+		t.Errorf("Bad JSON have %s, %v wants []", data, err)
+	}
+}
+
+func TestMarshalJSONNonFinite(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"F", vt_FLOAT}}, [][]interface{}{
+		{math.NaN()}, {math.Inf(1)}, {math.Inf(-1)}, {0.5},
+	})
+	data, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("Marshal produced error %v", err)
+	}
+	expected := `[{"F":"NaN"},{"F":"Infinity"},{"F":"-Infinity"},{"F":0.5}]`
+	if string(data) != expected {
+		t.Errorf("Bad JSON have %s wants %s", data, expected)
+	}
+}
+
+func TestMarshalJSONUnreadRows(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"I", vt_INT}}, [][]interface{}{
+		{int32(1)}, {int32(2)},
+	})
+	table.AdvanceRow()
+	if data, err := json.Marshal(table); err != nil || string(data) != `[{"I":2}]` {
+		t.Errorf("Bad JSON have %s, %v wants [{\"I\":2}]", data, err)
+	}
+}
+
+func TestMarshalJSONGeography(t *testing.T) {
+	ring := []GeographyPoint{{0, 0}, {1, 0}, {0, 1}, {0, 0}}
+	table := newTestTable(t, []testColumn{{"G", vt_GEOGRAPHY}}, [][]interface{}{
+		{&Geography{Rings: [][]GeographyPoint{ring}}},
+	})
+	var rows []map[string]string
+	data, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("Marshal produced error %v", err)
+	}
+	if err := json.Unmarshal(data, &rows); err != nil || len(rows) != 1 {
+		t.Fatalf("Bad JSON %s: %v", data, err)
+	}
+	if g := rows[0]["G"]; len(g) < 10 || g[:10] != "POLYGON ((" {
+		t.Errorf("Bad geography %v", g)
+	}
+}
+
+func TestCallJSON(t *testing.T) {
+	calls := make(chan invocation, 2)
+	server := recordingServer(t, calls)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+
+	order := struct {
+		ID    int      `json:"id"`
+		Items []string `json:"items"`
+	}{7, []string{"a", "b"}}
+	testVals := []struct {
+		arg      interface{}
+		expected string
+	}{
+		{order, `{"id":7,"items":["a","b"]}`},
+		{json.RawMessage(`{"raw": true}`), `{"raw":true}`},
+	}
+	for _, tv := range testVals {
+		if _, err := conn.CallJSON("Ingest", tv.arg); err != nil {
+			t.Fatalf("CallJSON produced error %v", err)
+		}
+		var params bytes.Buffer
+		writeParameterSet(&params, []interface{}{tv.expected})
+		if call := <-calls; call.proc != "Ingest" || !bytes.Equal(call.params, params.Bytes()) {
+			t.Errorf("CallJSON sent %v %q wants %q", call.proc, call.params, params.Bytes())
+		}
+	}
+
+	if _, err := conn.CallJSON("Ingest", make(chan int)); err == nil {
+		t.Errorf("Expected error for a value JSON can not encode")
+	}
+}
@@ -0,0 +1,94 @@
+package voltdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// keepalive.go detects half-open connections. A peer that vanished
+// without closing the socket is otherwise noticed only when a write
+// fails, which may be never for an idle Conn.
+
+// EnableKeepalive starts sending @Ping every interval. A ping not
+// answered within interval is missed; after missedThreshold misses
+// in a row the connection is marked lost, failing its pending calls.
+// A Conn with a RetryPolicy then reconnects on the next call. Pings
+// do not wait for a slot under SetMaxOutstanding, so a Conn whose
+// slots are all taken by unanswered calls is still detected. An
+// interval of zero stops the heartbeat.
+func (conn *Conn) EnableKeepalive(interval time.Duration, missedThreshold int) {
+	if missedThreshold < 1 {
+		missedThreshold = 1
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.keepalive != nil {
+		close(conn.keepalive)
+		conn.keepalive = nil
+	}
+	if interval <= 0 || conn.closed {
+		return
+	}
+	conn.keepalive = make(chan struct{})
+	go conn.heartbeat(interval, missedThreshold, conn.keepalive)
+}
+
+func (conn *Conn) heartbeat(interval time.Duration, missedThreshold int, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		conn.mu.Lock()
+		gen, failed := conn.gen, conn.err != nil
+		conn.mu.Unlock()
+		if failed {
+			missed = 0
+			continue
+		}
+		// a ping that can not be written within interval fails the
+		// connection, as with SetWriteTimeout.
+		opts := newCallOptions()
+		opts.reserved = true
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		future, err := conn.send(ctx, "@Ping", nil, opts, nil)
+		cancel()
+		if err != nil {
+			continue
+		}
+		timeout := time.NewTimer(interval)
+		select {
+		case <-future.done:
+			missed = 0
+		case <-timeout.C:
+			conn.abandon(future.handle)
+			missed++
+			conn.logf("Heartbeat missed, %d of %d.", missed, missedThreshold)
+		case <-stop:
+			conn.abandon(future.handle)
+		}
+		timeout.Stop()
+		if missed >= missedThreshold {
+			conn.expire(gen, fmt.Errorf("%d heartbeats missed.", missed))
+			missed = 0
+		}
+	}
+}
+
+// expire marks socket generation gen lost with err and closes it.
+func (conn *Conn) expire(gen int, err error) {
+	conn.fail(gen, &connectionError{err, true})
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	conn.mu.Lock()
+	current := conn.gen == gen
+	conn.mu.Unlock()
+	if current && conn.netConn != nil {
+		conn.netConn.Close()
+	}
+}
@@ -0,0 +1,88 @@
+package voltdb
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// silentServer answers the first n invocations and then reads
+// without answering, leaving the socket open.
+func silentServer(t *testing.T, n int) *testServer {
+	return newTestServer(t, func(c net.Conn) {
+		for i := 0; i < n; i++ {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+		}
+		io.Copy(io.Discard, c)
+	})
+}
+
+func TestKeepaliveDetectsSilentServer(t *testing.T) {
+	server := silentServer(t, 2)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.EnableKeepalive(10*time.Millisecond, 3)
+	deadline := time.Now().Add(2 * time.Second)
+	for !conn.failed() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected connection to fail after missed heartbeats")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := conn.Call("After"); err == nil {
+		t.Errorf("Expected error calling on an expired connection")
+	}
+}
+
+func TestKeepaliveAtMaxOutstanding(t *testing.T) {
+	server := silentServer(t, 0)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetMaxOutstanding(1)
+	if _, err := conn.CallAsync("Unanswered"); err != nil {
+		t.Fatalf("CallAsync produced error %v", err)
+	}
+	conn.EnableKeepalive(10*time.Millisecond, 3)
+	deadline := time.Now().Add(2 * time.Second)
+	for !conn.failed() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected missed heartbeats to fail a Conn with every slot taken")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKeepaliveHealthyServer(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.EnableKeepalive(5*time.Millisecond, 2)
+	time.Sleep(50 * time.Millisecond)
+	if conn.failed() {
+		t.Errorf("Expected answered heartbeats to keep the connection")
+	}
+	conn.EnableKeepalive(0, 0)
+	if conn.keepalive != nil {
+		t.Errorf("Expected zero interval to stop the heartbeat")
+	}
+}
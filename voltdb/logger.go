@@ -0,0 +1,27 @@
+package voltdb
+
+// Logger receives reports of what a Conn does on its own: lost
+// connections, reconnects, discarded late responses and missed
+// heartbeats. A *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SetLogger makes the Conn report its internal events to logger. A
+// nil logger, the default, discards them.
+func (conn *Conn) SetLogger(logger Logger) {
+	conn.mu.Lock()
+	conn.logger = logger
+	conn.mu.Unlock()
+}
+
+// logf reports an event to the Conn's Logger, if it has one. conn.mu
+// must not be held.
+func (conn *Conn) logf(format string, v ...interface{}) {
+	conn.mu.Lock()
+	logger := conn.logger
+	conn.mu.Unlock()
+	if logger != nil {
+		logger.Printf("voltdb: "+format, v...)
+	}
+}
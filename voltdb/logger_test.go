@@ -0,0 +1,75 @@
+package voltdb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger keeps the messages logged to it.
+type recordingLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.msgs, "\n")
+}
+
+func TestLoggerReconnect(t *testing.T) {
+	server := dropFirstServer(t)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	var logger recordingLogger
+	conn.SetLogger(&logger)
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	if _, err := conn.CallIdempotent("Retried"); err != nil {
+		t.Fatalf("CallIdempotent produced error %v", err)
+	}
+	logged := logger.String()
+	for _, event := range []string{"voltdb: Connection lost with 1 calls pending",
+		"voltdb: Reconnecting.", "voltdb: Reconnected to host 1."} {
+		if !strings.Contains(logged, event) {
+			t.Errorf("Expected %q to be logged, have\n%v", event, logged)
+		}
+	}
+
+	// a nil Logger silences the Conn.
+	conn.SetLogger(nil)
+	before := logger.String()
+	conn.Close()
+	if after := logger.String(); after != before {
+		t.Errorf("Logged after SetLogger(nil): %v", after)
+	}
+}
+
+func TestLoggerCloseNotLogged(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	var logger recordingLogger
+	conn.SetLogger(&logger)
+	conn.Close()
+	if logged := logger.String(); logged != "" {
+		t.Errorf("Close logged %v", logged)
+	}
+}
@@ -0,0 +1,424 @@
+package voltdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+// MockServer is an in-process stand-in for a VoltDB server, for
+// testing code that uses this package without a cluster. It accepts
+// any login and answers each invocation with the response of the
+// handler registered for its procedure.
+type MockServer struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex // protects the fields below
+	handlers map[string]MockHandler
+	conns    map[net.Conn]bool
+}
+
+// MockHandler answers an invocation with params, decoded as the Go
+// types Call accepts, with SQL NULL as nil.
+type MockHandler func(params []interface{}) *MockResponse
+
+// MockResponse is the reply of a MockServer. A zero Status is sent
+// as SUCCESS.
+type MockResponse struct {
+	Status       Status
+	StatusString string
+	Tables       []*MockTable
+}
+
+// MockTable is a result table. The type of each column is that of
+// its first non-nil value: int8 TINYINT, int16 SMALLINT, int32
+// INTEGER, int64 or int BIGINT, float64 FLOAT, string STRING, []byte
+// VARBINARY, time.Time TIMESTAMP, *big.Rat DECIMAL, GeographyPoint
+// GEOGRAPHY_POINT and *Geography GEOGRAPHY. A column of only NULLs
+// is a STRING.
+type MockTable struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// NewMockServer starts a MockServer listening on a local port.
+func NewMockServer() (*MockServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := &MockServer{
+		listener: listener,
+		handlers: make(map[string]MockHandler),
+		conns:    make(map[net.Conn]bool),
+	}
+	server.wg.Add(1)
+	go server.accept()
+	return server, nil
+}
+
+// Addr returns the host and port to connect to.
+func (server *MockServer) Addr() string {
+	return server.listener.Addr().String()
+}
+
+// Handle makes handler answer the invocations of procedure.
+func (server *MockServer) Handle(procedure string, handler MockHandler) {
+	server.mu.Lock()
+	server.handlers[procedure] = handler
+	server.mu.Unlock()
+}
+
+// Respond makes the server answer every invocation of procedure
+// with rsp.
+func (server *MockServer) Respond(procedure string, rsp *MockResponse) {
+	server.Handle(procedure, func([]interface{}) *MockResponse {
+		return rsp
+	})
+}
+
+// Close stops the server and closes its connections.
+func (server *MockServer) Close() error {
+	err := server.listener.Close()
+	server.mu.Lock()
+	for c := range server.conns {
+		c.Close()
+	}
+	server.mu.Unlock()
+	server.wg.Wait()
+	return err
+}
+
+func (server *MockServer) accept() {
+	defer server.wg.Done()
+	for {
+		c, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		server.mu.Lock()
+		server.conns[c] = true
+		server.mu.Unlock()
+		server.wg.Add(1)
+		go server.serve(c)
+	}
+}
+
+// serve logs c in and answers its invocations until it is closed.
+func (server *MockServer) serve(c net.Conn) {
+	defer server.wg.Done()
+	defer func() {
+		server.mu.Lock()
+		delete(server.conns, c)
+		server.mu.Unlock()
+		c.Close()
+	}()
+	if _, err := readMessage(c); err != nil {
+		return
+	}
+	if writeMessage(c, mockLoginResponse()) != nil {
+		return
+	}
+	for {
+		frame, err := readFrame(c, maxMessageSize)
+		if err != nil {
+			return
+		}
+		payload := frame[messageHeaderSize:]
+		r := bytes.NewReader(payload)
+		if frame[4] == invocationBatchTimeout {
+			if _, err := readBatchTimeout(r); err != nil {
+				return
+			}
+		}
+		procedure, err := readString(r)
+		if err != nil {
+			return
+		}
+		handle, err := readLong(r)
+		if err != nil {
+			return
+		}
+		var rsp *MockResponse
+		if params, err := readParameterSet(r); err != nil {
+			rsp = &MockResponse{Status: GRACEFUL_FAILURE, StatusString: err.Error()}
+		} else {
+			rsp = server.invoke(procedure, params)
+		}
+		msg, err := rsp.serialize(handle)
+		if err != nil {
+			msg, _ = (&MockResponse{Status: UNEXPECTED_FAILURE, StatusString: err.Error()}).serialize(handle)
+		}
+		if writeMessage(c, msg) != nil {
+			return
+		}
+	}
+}
+
+// mockLoginResponse returns the payload accepting a login.
+func mockLoginResponse() []byte {
+	var login bytes.Buffer
+	writeByte(&login, 0)              // authentication result
+	writeInt(&login, 0)               // host id
+	writeLong(&login, 0)              // connection id
+	writeLong(&login, 0)              // cluster start timestamp
+	writeInt(&login, 0x7F000001)      // leader address
+	writeString(&login, "MockServer") // build string
+	return login.Bytes()
+}
+
+func (server *MockServer) invoke(procedure string, params []interface{}) *MockResponse {
+	server.mu.Lock()
+	handler := server.handlers[procedure]
+	server.mu.Unlock()
+	if handler == nil {
+		return &MockResponse{Status: GRACEFUL_FAILURE,
+			StatusString: fmt.Sprintf("Procedure %v was not found", procedure)}
+	}
+	if rsp := handler(params); rsp != nil {
+		return rsp
+	}
+	return &MockResponse{}
+}
+
+// serialize returns the response message payload for handle.
+func (rsp *MockResponse) serialize(handle int64) ([]byte, error) {
+	var b bytes.Buffer
+	writeLong(&b, handle)
+	status := rsp.Status
+	if status == 0 {
+		status = SUCCESS
+	}
+	if rsp.StatusString != "" {
+		writeByte(&b, 1<<5) // fields present: status string
+		writeByte(&b, int8(status))
+		writeString(&b, rsp.StatusString)
+	} else {
+		writeByte(&b, 0)
+		writeByte(&b, int8(status))
+	}
+	writeByte(&b, UNINITIALIZED_APP_STATUS_CODE)
+	writeInt(&b, 0) // cluster round trip time
+	writeShort(&b, int16(len(rsp.Tables)))
+	for _, table := range rsp.Tables {
+		if err := table.serialize(&b); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func (table *MockTable) serialize(w io.Writer) error {
+	types := make([]int8, len(table.Columns))
+	for idx := range types {
+		types[idx] = vt_STRING
+		for _, row := range table.Rows {
+			if idx < len(row) && row[idx] != nil {
+				vt, err := columnTypeOf(row[idx])
+				if err != nil {
+					return fmt.Errorf("Column %v: %v", table.Columns[idx], err)
+				}
+				types[idx] = vt
+				break
+			}
+		}
+	}
+	return writeTable(w, UNINITIALIZED_TABLE_STATUS_CODE, table.Columns, types, table.Rows)
+}
+
+// columnTypeOf returns the column type of val; see MockTable.
+func columnTypeOf(val interface{}) (int8, error) {
+	switch val.(type) {
+	case int8:
+		return vt_TINYINT, nil
+	case int16:
+		return vt_SHORT, nil
+	case int32:
+		return vt_INT, nil
+	case int64, int:
+		return vt_LONG, nil
+	case float64:
+		return vt_FLOAT, nil
+	case string:
+		return vt_STRING, nil
+	case []byte:
+		return vt_VARBIN, nil
+	case time.Time:
+		return vt_TIMESTAMP, nil
+	case *big.Rat:
+		return vt_DECIMAL, nil
+	case GeographyPoint:
+		return vt_POINT, nil
+	case *Geography:
+		return vt_GEOGRAPHY, nil
+	}
+	return 0, fmt.Errorf("Can not send %T values.", val)
+}
+
+// writeTable writes a table with the given columns and rows, each
+// row holding one value of the column's type, or nil, per column.
+func writeTable(w io.Writer, status int8, names []string, types []int8, rows [][]interface{}) error {
+	var meta, data bytes.Buffer
+	writeByte(&meta, status)
+	writeShort(&meta, int16(len(types)))
+	for _, vt := range types {
+		writeByte(&meta, vt)
+	}
+	for _, name := range names {
+		writeString(&meta, name)
+	}
+	var row bytes.Buffer
+	for _, vals := range rows {
+		if len(vals) != len(types) {
+			return fmt.Errorf("Row has %d values for %d columns.", len(vals), len(types))
+		}
+		row.Reset()
+		for idx, val := range vals {
+			if err := writeColumnValue(&row, types[idx], val); err != nil {
+				return err
+			}
+		}
+		writeInt(&data, int32(row.Len()))
+		data.Write(row.Bytes())
+	}
+	writeInt(w, int32(4+meta.Len()+4+data.Len()))
+	writeInt(w, int32(meta.Len()))
+	w.Write(meta.Bytes())
+	writeInt(w, int32(len(rows)))
+	_, err := w.Write(data.Bytes())
+	return err
+}
+
+// writeColumnValue writes val, or the NULL of type vt if val is nil,
+// as a table value of type vt.
+func writeColumnValue(w io.Writer, vt int8, val interface{}) error {
+	if val == nil {
+		return writeColumnNull(w, vt)
+	}
+	if valType, err := columnTypeOf(val); err != nil {
+		return err
+	} else if valType != vt {
+		return protocolError(ErrUnexpectedType, "Can not write %T as column type %d.", val, vt)
+	}
+	switch x := val.(type) {
+	case int8:
+		return writeByte(w, x)
+	case int16:
+		return writeShort(w, x)
+	case int32:
+		return writeInt(w, x)
+	case int64:
+		return writeLong(w, x)
+	case int:
+		return writeLong(w, int64(x))
+	case float64:
+		return writeFloat(w, x)
+	case string:
+		return writeString(w, x)
+	case []byte:
+		return writeByteString(w, x)
+	case time.Time:
+		return writeTimestamp(w, x)
+	case *big.Rat:
+		return writeDecimal(w, x)
+	case GeographyPoint:
+		return writeGeographyPoint(w, &x)
+	case *Geography:
+		return writeGeography(w, x)
+	}
+	return nil
+}
+
+// writeColumnNull writes the NULL value of column type vt.
+func writeColumnNull(w io.Writer, vt int8) error {
+	switch vt {
+	case vt_BOOL:
+		return writeByte(w, byteNull)
+	case vt_SHORT:
+		return writeShort(w, shortNull)
+	case vt_INT:
+		return writeInt(w, intNull)
+	case vt_LONG:
+		return writeLong(w, longNull)
+	case vt_FLOAT:
+		return writeFloat(w, floatNull)
+	case vt_TIMESTAMP:
+		return writeLong(w, timestampNull)
+	case vt_STRING, vt_VARBIN, vt_GEOGRAPHY:
+		return writeInt(w, -1)
+	case vt_DECIMAL:
+		return writeDecimal(w, nil)
+	case vt_POINT:
+		return writeGeographyPoint(w, nil)
+	}
+	return protocolError(ErrUnexpectedType, "Unknown column type %d.", vt)
+}
+
+// readParameterSet reads a ParameterSet written by writeParameterSet.
+// NULLs, typed or not, are returned as nil.
+func readParameterSet(r io.Reader) ([]interface{}, error) {
+	count, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]interface{}, count)
+	for idx := range params {
+		if params[idx], err = readParam(r); err != nil {
+			return nil, fmt.Errorf("Parameter %d: %v", idx, err)
+		}
+	}
+	return params, nil
+}
+
+func readParam(r io.Reader) (interface{}, error) {
+	vt, err := readByte(r)
+	if err != nil {
+		return nil, err
+	}
+	var val interface{}
+	var isNull bool
+	switch vt {
+	case vt_NULL:
+		return nil, nil
+	case vt_BOOL:
+		val, isNull, err = readNullableByte(r)
+	case vt_SHORT:
+		val, isNull, err = readNullableShort(r)
+	case vt_INT:
+		val, isNull, err = readNullableInt(r)
+	case vt_LONG:
+		val, isNull, err = readNullableLong(r)
+	case vt_FLOAT:
+		val, isNull, err = readNullableFloat(r)
+	case vt_STRING:
+		val, isNull, err = readNullableString(r)
+	case vt_TIMESTAMP:
+		val, isNull, err = readNullableTimestamp(r)
+	case vt_DECIMAL:
+		var d *big.Rat
+		d, err = readDecimal(r)
+		val, isNull = d, d == nil
+	case vt_VARBIN:
+		var bs []byte
+		bs, err = readVarbinary(r)
+		val, isNull = bs, bs == nil
+	case vt_POINT:
+		val, isNull, err = readGeographyPoint(r)
+	case vt_GEOGRAPHY:
+		var g *Geography
+		g, err = readGeography(r)
+		val, isNull = g, g == nil
+	case vt_ARRAY:
+		val, err = readArray(r)
+	default:
+		return nil, protocolError(ErrUnexpectedType, "Unknown parameter type %d.", vt)
+	}
+	if err != nil || isNull {
+		return nil, err
+	}
+	return val, nil
+}
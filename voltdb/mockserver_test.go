@@ -0,0 +1,232 @@
+package voltdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newMockServer starts a MockServer for a test.
+func newMockServer(t *testing.T) *MockServer {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	return server
+}
+
+// echoResponse is the MockResponse holding the table of echoTable.
+func echoResponse(val string) *MockResponse {
+	return &MockResponse{Tables: []*MockTable{{
+		Columns: []string{"ECHO"},
+		Rows:    [][]interface{}{{val}},
+	}}}
+}
+
+// handleEcho makes server answer each of procs with the echoResponse
+// of its name, once wait, if any, returns.
+func handleEcho(server *MockServer, wait func(), procs ...string) {
+	for _, proc := range procs {
+		proc := proc
+		server.Handle(proc, func([]interface{}) *MockResponse {
+			if wait != nil {
+				wait()
+			}
+			return echoResponse(proc)
+		})
+	}
+}
+
+func ExampleMockServer() {
+	server, err := NewMockServer()
+	if err != nil {
+		panic(err)
+	}
+	defer server.Close()
+	server.Respond("GetUser", &MockResponse{Tables: []*MockTable{{
+		Columns: []string{"ID", "NAME"},
+		Rows:    [][]interface{}{{int64(1), "alice"}},
+	}}})
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	rsp, err := conn.Call("GetUser", 1)
+	if err != nil {
+		panic(err)
+	}
+	table := rsp.Table(0)
+	for table.AdvanceRow() {
+		var id int64
+		var name string
+		table.Scan(&id, &name)
+		fmt.Println(id, name)
+	}
+	// Output: 1 alice
+}
+
+func TestMockServerParams(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	defer server.Close()
+	received := make(chan []interface{}, 1)
+	server.Handle("Record", func(params []interface{}) *MockResponse {
+		received <- params
+		return nil
+	})
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 0, time.UTC)
+	params := []interface{}{int8(1), int16(2), int32(3), int64(4), 2.5, "five",
+		ts, big.NewRat(3, 2), []byte{6}, []int32{7, 8}, GeographyPoint{1, 2}, nil, (*string)(nil)}
+	rsp, err := conn.Call("Record", params...)
+	if err != nil || rsp.Status() != SUCCESS {
+		t.Fatalf("Call produced %v, %v", rsp, err)
+	}
+	expected := params[:len(params)-1]
+	expected = append(expected, nil)
+	if have := <-received; !reflect.DeepEqual(have, expected) {
+		t.Errorf("Bad params have %v wants %v", have, expected)
+	}
+}
+
+func TestMockServerQueryTimeout(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	defer server.Close()
+	received := make(chan []interface{}, 1)
+	server.Handle("Timed", func(params []interface{}) *MockResponse {
+		received <- params
+		return nil
+	})
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	rsp, err := conn.CallWithOptions("Timed", []interface{}{int32(9)}, WithQueryTimeout(250))
+	if err != nil || rsp.Status() != SUCCESS {
+		t.Fatalf("CallWithOptions produced %v, %v", rsp, err)
+	}
+	if have := <-received; !reflect.DeepEqual(have, []interface{}{int32(9)}) {
+		t.Errorf("Bad params have %v wants [9]", have)
+	}
+}
+
+func TestMockServerBadParameterCount(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	defer server.Close()
+
+	c, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("Dial produced error %v", err)
+	}
+	defer c.Close()
+	login, _ := serializeLoginMessage("user", "", SHA256)
+	writeMessage(c, login.Bytes())
+	if _, err := readMessage(c); err != nil {
+		t.Fatalf("Login produced error %v", err)
+	}
+	call, _ := newInvocationEncoder(noQueryTimeout)
+	writeString(call, "Negative")
+	writeLong(call, 7)
+	writeShort(call, -1) // parameter count
+	if err := call.flush(c); err != nil {
+		t.Fatalf("Write produced error %v", err)
+	}
+	payload, err := readMessage(c)
+	if err != nil {
+		t.Fatalf("Expected a response to a negative parameter count, have %v", err)
+	}
+	rsp, err := decodeCallResponse(payload)
+	if err != nil || rsp.Status() != GRACEFUL_FAILURE {
+		t.Errorf("Expected GRACEFUL_FAILURE have %v, %v", rsp, err)
+	}
+}
+
+func TestMockServerStatus(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	defer server.Close()
+	server.Respond("Fail", &MockResponse{Status: USER_ABORT, StatusString: "rolled back"})
+	server.Respond("BadTable", &MockResponse{Tables: []*MockTable{{
+		Columns: []string{"X"},
+		Rows:    [][]interface{}{{struct{}{}}},
+	}}})
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	testVals := []struct {
+		proc         string
+		status       Status
+		statusString string
+	}{
+		{"Fail", USER_ABORT, "rolled back"},
+		{"Missing", GRACEFUL_FAILURE, "Procedure Missing was not found"},
+		{"BadTable", UNEXPECTED_FAILURE, "Column X: Can not send struct {} values."},
+	}
+	for _, tv := range testVals {
+		rsp, err := conn.Call(tv.proc)
+		if err != nil {
+			t.Fatalf("Call(%v) produced error %v", tv.proc, err)
+		}
+		if rsp.Status() != tv.status || rsp.StatusString() != tv.statusString {
+			t.Errorf("Call(%v) has %v %q wants %v %q", tv.proc,
+				rsp.Status(), rsp.StatusString(), tv.status, tv.statusString)
+		}
+	}
+}
+
+func TestMockServerNullColumns(t *testing.T) {
+	table := &MockTable{Columns: []string{"N", "S"}, Rows: [][]interface{}{{nil, nil}, {7, nil}}}
+	rsp, err := (&MockResponse{Tables: []*MockTable{table}}).serialize(1)
+	if err != nil {
+		t.Fatalf("serialize produced error %v", err)
+	}
+	decoded, err := deserializeCallResponse(bytes.NewReader(rsp))
+	if err != nil {
+		t.Fatalf("deserializeCallResponse produced error %v", err)
+	}
+	if types := decoded.Table(0).ColumnTypes(); types[0] != vt_LONG || types[1] != vt_STRING {
+		t.Errorf("Bad column types %v", types)
+	}
+}
+
+func TestMockServerClose(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	server.Close()
+	if _, err := conn.Call("Any"); err == nil {
+		t.Errorf("Expected error calling a closed MockServer")
+	}
+}
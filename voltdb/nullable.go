@@ -0,0 +1,83 @@
+package voltdb
+
+import (
+	"time"
+)
+
+// nullable.go holds column values that may be SQL NULL, in the style
+// of database/sql's NullString. Valid is false for NULL.
+
+// NullableInt32 is an INTEGER that may be NULL.
+type NullableInt32 struct {
+	Value int32
+	Valid bool
+}
+
+// NullableInt64 is a BIGINT that may be NULL.
+type NullableInt64 struct {
+	Value int64
+	Valid bool
+}
+
+// NullableFloat64 is a FLOAT that may be NULL.
+type NullableFloat64 struct {
+	Value float64
+	Valid bool
+}
+
+// NullableString is a STRING that may be NULL.
+type NullableString struct {
+	Value string
+	Valid bool
+}
+
+// NullableTime is a TIMESTAMP that may be NULL.
+type NullableTime struct {
+	Value time.Time
+	Valid bool
+}
+
+// GetNullableInt returns the INTEGER value of column colIndex.
+func (table *Table) GetNullableInt(colIndex int) (NullableInt32, error) {
+	v, isNull, err := table.GetInt(colIndex)
+	if err != nil {
+		return NullableInt32{}, err
+	}
+	return NullableInt32{v, !isNull}, nil
+}
+
+// GetNullableLong returns the BIGINT value of column colIndex.
+func (table *Table) GetNullableLong(colIndex int) (NullableInt64, error) {
+	v, isNull, err := table.GetLong(colIndex)
+	if err != nil {
+		return NullableInt64{}, err
+	}
+	return NullableInt64{v, !isNull}, nil
+}
+
+// GetNullableFloat returns the FLOAT value of column colIndex.
+func (table *Table) GetNullableFloat(colIndex int) (NullableFloat64, error) {
+	v, isNull, err := table.GetFloat(colIndex)
+	if err != nil {
+		return NullableFloat64{}, err
+	}
+	return NullableFloat64{v, !isNull}, nil
+}
+
+// GetNullableString returns the STRING value of column colIndex.
+func (table *Table) GetNullableString(colIndex int) (NullableString, error) {
+	v, isNull, err := table.GetString(colIndex)
+	if err != nil {
+		return NullableString{}, err
+	}
+	return NullableString{v, !isNull}, nil
+}
+
+// GetNullableTimestamp returns the TIMESTAMP value of column colIndex.
+func (table *Table) GetNullableTimestamp(colIndex int) (NullableTime, error) {
+	v, isNull, err := table.GetTimestamp(colIndex)
+	if err != nil {
+		return NullableTime{}, err
+	}
+	return NullableTime{v, !isNull}, nil
+}
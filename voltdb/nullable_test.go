@@ -0,0 +1,52 @@
+package voltdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNullableAccessors(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 0, time.UTC)
+	cols := []testColumn{{"I", vt_INT}, {"L", vt_LONG}, {"F", vt_FLOAT},
+		{"S", vt_STRING}, {"T", vt_TIMESTAMP}}
+	table := newTestTable(t, cols, [][]interface{}{
+		{int32(7), int64(8), 2.5, "seven", ts},
+		{nil, nil, nil, nil, nil},
+	})
+
+	table.AdvanceRow()
+	if v, err := table.GetNullableInt(0); v != (NullableInt32{7, true}) || err != nil {
+		t.Errorf("Bad GetNullableInt %v, %v", v, err)
+	}
+	if v, err := table.GetNullableLong(1); v != (NullableInt64{8, true}) || err != nil {
+		t.Errorf("Bad GetNullableLong %v, %v", v, err)
+	}
+	if v, err := table.GetNullableFloat(2); v != (NullableFloat64{2.5, true}) || err != nil {
+		t.Errorf("Bad GetNullableFloat %v, %v", v, err)
+	}
+	if v, err := table.GetNullableString(3); v != (NullableString{"seven", true}) || err != nil {
+		t.Errorf("Bad GetNullableString %v, %v", v, err)
+	}
+	if v, err := table.GetNullableTimestamp(4); !v.Value.Equal(ts) || !v.Valid || err != nil {
+		t.Errorf("Bad GetNullableTimestamp %v, %v", v, err)
+	}
+
+	table.AdvanceRow()
+	var i NullableInt32
+	var l NullableInt64
+	var f NullableFloat64
+	var s NullableString
+	var tm NullableTime
+	if err := table.Scan(&i, &l, &f, &s, &tm); err != nil {
+		t.Fatalf("Scan produced error %v", err)
+	}
+	if i.Valid || l.Valid || f.Valid || s.Valid || tm.Valid {
+		t.Errorf("Expected NULLs, have %v, %v, %v, %v, %v", i, l, f, s, tm)
+	}
+	if v, err := table.GetNullableString(3); v.Valid || err != nil {
+		t.Errorf("Bad NULL GetNullableString %v, %v", v, err)
+	}
+	if v, err := table.GetNullableInt(3); v.Valid || err == nil {
+		t.Errorf("Expected invalid value and error for a STRING column, have %v, %v", v, err)
+	}
+}
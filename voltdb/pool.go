@@ -0,0 +1,134 @@
+package voltdb
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Pool is a fixed-size set of Conns spread across the hosts of a
+// database. Calls are handed to members round-robin. A member whose
+// connection has failed is replaced by a new Conn to the same host
+// the next time it is chosen.
+type Pool struct {
+	hosts  []string
+	user   string
+	passwd string
+
+	next     uint64 // round-robin counter, updated atomically
+	inflight sync.WaitGroup
+	mu       sync.Mutex // protects members and closed
+	members  []*Conn
+	closed   bool
+}
+
+// NewPool opens size connections, assigning them to hosts in turn.
+func NewPool(hosts []string, user string, passwd string, size int) (*Pool, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("Pool needs at least one host.")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("Pool size must be positive, not %d.", size)
+	}
+	pool := &Pool{hosts: hosts, user: user, passwd: passwd}
+	pool.members = make([]*Conn, size)
+	for idx := range pool.members {
+		conn, err := NewConnection(user, passwd, pool.host(idx))
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.members[idx] = conn
+	}
+	return pool, nil
+}
+
+// host returns the host of member idx.
+func (pool *Pool) host(idx int) string {
+	return pool.hosts[idx%len(pool.hosts)]
+}
+
+// Call invokes procedure on the next healthy member. Calls that fail
+// because a member's connection was already lost are retried on the
+// next member.
+func (pool *Pool) Call(procedure string, params ...interface{}) (*Response, error) {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return nil, fmt.Errorf("Can not call procedure on closed Pool.")
+	}
+	pool.inflight.Add(1)
+	size := len(pool.members)
+	pool.mu.Unlock()
+	defer pool.inflight.Done()
+
+	var err error
+	for tries := 0; tries < size; tries++ {
+		idx := int(atomic.AddUint64(&pool.next, 1) % uint64(size))
+		var conn *Conn
+		if conn, err = pool.member(idx); err != nil {
+			continue
+		}
+		var rsp *Response
+		rsp, err = conn.Call(procedure, params...)
+		if lost, ok := err.(*connectionError); ok && !lost.sent {
+			continue
+		}
+		return rsp, err
+	}
+	return nil, err
+}
+
+// member returns member idx, first replacing it if its connection
+// has failed. The replacement is dialed without holding pool.mu, so a
+// slow host does not hold up calls to the other members; if another
+// call replaced the member meanwhile, its Conn is used and the new
+// one closed.
+func (pool *Pool) member(idx int) (*Conn, error) {
+	pool.mu.Lock()
+	dead := pool.members[idx]
+	pool.mu.Unlock()
+	if dead != nil && !dead.failed() {
+		return dead, nil
+	}
+
+	conn, err := NewConnection(pool.user, pool.passwd, pool.host(idx))
+	if err != nil {
+		return nil, err
+	}
+	pool.mu.Lock()
+	current := pool.members[idx]
+	if current != nil && current != dead && !current.failed() {
+		pool.mu.Unlock()
+		conn.Close()
+		return current, nil
+	}
+	pool.members[idx] = conn
+	pool.mu.Unlock()
+	if current != nil {
+		current.Close()
+	}
+	return conn, nil
+}
+
+// Close waits for in-flight calls to finish and closes every member.
+func (pool *Pool) Close() error {
+	pool.mu.Lock()
+	pool.closed = true
+	pool.mu.Unlock()
+	pool.inflight.Wait()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	var err error
+	for idx, conn := range pool.members {
+		if conn == nil {
+			continue
+		}
+		if closeErr := conn.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		pool.members[idx] = nil
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package voltdb
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolDistributesCalls(t *testing.T) {
+	var mu sync.Mutex
+	callsByConn := make(map[net.Conn]int)
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			callsByConn[c]++
+			mu.Unlock()
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+		}
+	})
+	defer server.close()
+
+	pool, err := NewPool([]string{server.addr()}, "user", "", 3)
+	if err != nil {
+		t.Fatalf("NewPool produced error %v", err)
+	}
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.Call("Spread"); err != nil {
+				t.Errorf("Call produced error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(callsByConn) != 3 {
+		t.Errorf("Expected calls on 3 connections, have %v", len(callsByConn))
+	}
+	for _, calls := range callsByConn {
+		if calls != 10 {
+			t.Errorf("Expected 10 calls per connection, have %v", callsByConn)
+			break
+		}
+	}
+}
+
+func TestPoolReplacesDeadMember(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	pool, err := NewPool([]string{server.addr()}, "user", "", 2)
+	if err != nil {
+		t.Fatalf("NewPool produced error %v", err)
+	}
+	defer pool.Close()
+
+	dead := pool.members[0]
+	dead.netConn.Close()
+	for !dead.failed() {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := pool.Call("Replaced"); err != nil {
+			t.Errorf("Call produced error %v", err)
+		}
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if pool.members[0] == dead || pool.members[0] == nil {
+		t.Errorf("Expected dead member to be replaced")
+	}
+}
+
+func TestPoolReplacesMemberOnce(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	pool, err := NewPool([]string{server.addr()}, "user", "", 1)
+	if err != nil {
+		t.Fatalf("NewPool produced error %v", err)
+	}
+	defer pool.Close()
+
+	dead := pool.members[0]
+	dead.netConn.Close()
+	for !dead.failed() {
+		time.Sleep(time.Millisecond)
+	}
+	conns := make([]*Conn, 8)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if conns[i], err = pool.member(0); err != nil {
+				t.Errorf("member produced error %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	pool.mu.Lock()
+	installed := pool.members[0]
+	pool.mu.Unlock()
+	for _, conn := range conns {
+		if conn != installed {
+			t.Errorf("Expected every caller to get the installed member")
+		}
+	}
+	if _, err := installed.Call("Replaced"); err != nil {
+		t.Errorf("Call produced error %v", err)
+	}
+}
+
+func TestPoolDialsWithoutLock(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	// a host that accepts connections but never answers the login.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen produced error %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if c, err := listener.Accept(); err == nil {
+			accepted <- c
+		}
+	}()
+
+	pool, err := NewPool([]string{server.addr()}, "user", "", 2)
+	if err != nil {
+		t.Fatalf("NewPool produced error %v", err)
+	}
+	defer pool.Close()
+
+	pool.hosts = []string{server.addr(), listener.Addr().String()}
+	dead := pool.members[1]
+	dead.netConn.Close()
+	for !dead.failed() {
+		time.Sleep(time.Millisecond)
+	}
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := pool.member(1)
+		dialed <- err
+	}()
+	stuck := <-accepted
+	defer stuck.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.member(0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("member produced error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected a healthy member while another is dialing")
+	}
+	stuck.Close()
+	if err := <-dialed; err == nil {
+		t.Errorf("Expected error dialing a host that drops the login")
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	pool, err := NewPool([]string{server.addr()}, "user", "", 2)
+	if err != nil {
+		t.Fatalf("NewPool produced error %v", err)
+	}
+	members := append([]*Conn(nil), pool.members...)
+	if err := pool.Close(); err != nil {
+		t.Errorf("Close produced error %v", err)
+	}
+	for _, conn := range members {
+		if _, err := conn.Call("Closed"); err == nil {
+			t.Errorf("Expected member to be closed")
+		}
+	}
+	if _, err := pool.Call("Closed"); err == nil {
+		t.Errorf("Expected error calling on closed Pool")
+	}
+}
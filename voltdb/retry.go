@@ -0,0 +1,321 @@
+package voltdb
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// retry.go re-establishes lost connections. A Conn with a RetryPolicy
+// redials its server and logs in again when the connection drops.
+// A call that had not been sent when the loss was noticed is always
+// retried. A call that may have reached the server is only retried
+// if the caller marks it idempotent, to avoid duplicate writes.
+// Idempotent calls may also be retried on chosen response statuses;
+// see RetryOnStatus. With SetReplayOnReconnect, asynchronous and
+// batched idempotent calls are resent on the new connection too.
+
+// RetryPolicy bounds reconnect attempts. The delay before each
+// attempt doubles from BaseBackoff up to MaxBackoff.
+type RetryPolicy struct {
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// backoff returns the delay before retry number attempt (from 0).
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.BaseBackoff
+	for i := 0; i < attempt && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	return delay
+}
+
+// connectionError reports that the connection to the server failed.
+// sent is false if the failed call was never written.
+type connectionError struct {
+	err  error
+	sent bool
+}
+
+func (e *connectionError) Error() string {
+	return fmt.Sprintf("Connection lost: %v", e.err)
+}
+
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
+// SetRetryPolicy enables reconnecting under policy. A nil policy
+// disables reconnecting.
+func (conn *Conn) SetRetryPolicy(policy *RetryPolicy) {
+	conn.mu.Lock()
+	conn.retry = policy
+	conn.mu.Unlock()
+}
+
+// CallIdempotent is Call for procedures that are safe to run more
+// than once. If the connection is lost before the response arrives,
+// the call is retried on the re-established connection.
+func (conn *Conn) CallIdempotent(procedure string, params ...interface{}) (*Response, error) {
+	opts := newCallOptions()
+	opts.idempotent = true
+	return conn.call(procedure, params, opts)
+}
+
+// CallOption configures a call made with CallWithOptions.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	idempotent    bool
+	retryStatuses map[Status]bool
+	queryTimeout  int32 // noQueryTimeout unless set by WithQueryTimeout
+	reserved      bool  // exempt from SetMaxOutstanding, as heartbeats are
+}
+
+// newCallOptions returns the options of a call made without any.
+func newCallOptions() callOptions {
+	return callOptions{queryTimeout: noQueryTimeout}
+}
+
+// Idempotent marks the call safe to run more than once, as
+// CallIdempotent does.
+func Idempotent() CallOption {
+	return func(opts *callOptions) {
+		opts.idempotent = true
+	}
+}
+
+// RetryOnStatus retries an idempotent call whose response has one of
+// statuses, such as GRACEFUL_FAILURE during an elastic rejoin. Calls
+// not marked Idempotent are never retried on their status. Retries
+// follow the Conn's RetryPolicy, or DefaultStatusRetryPolicy if it
+// has none; once they run out the last response is returned.
+func RetryOnStatus(statuses ...Status) CallOption {
+	return func(opts *callOptions) {
+		if opts.retryStatuses == nil {
+			opts.retryStatuses = make(map[Status]bool)
+		}
+		for _, status := range statuses {
+			opts.retryStatuses[status] = true
+		}
+	}
+}
+
+// WithQueryTimeout has the server cancel the call's queries if they
+// run longer than ms milliseconds, in place of its configured query
+// timeout. The server answers a cancelled call with a failure
+// status. Zero disables the timeout for the call, which VoltDB only
+// allows admin connections to do.
+func WithQueryTimeout(ms int32) CallOption {
+	return func(opts *callOptions) {
+		if ms >= 0 {
+			opts.queryTimeout = ms
+		}
+	}
+}
+
+// DefaultStatusRetryPolicy bounds RetryOnStatus retries on a Conn
+// without a RetryPolicy.
+var DefaultStatusRetryPolicy = RetryPolicy{
+	MaxRetries:  3,
+	BaseBackoff: 10 * time.Millisecond,
+	MaxBackoff:  time.Second,
+}
+
+// CallWithOptions is Call configured by opts.
+func (conn *Conn) CallWithOptions(procedure string, params []interface{}, opts ...CallOption) (*Response, error) {
+	options := newCallOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return conn.call(procedure, params, options)
+}
+
+func (conn *Conn) call(procedure string, params []interface{}, opts callOptions) (*Response, error) {
+	for attempt := 0; ; attempt++ {
+		future, err := conn.send(context.Background(), procedure, params, opts, nil)
+		var rsp *Response
+		if err == nil {
+			rsp, err = future.Get()
+		}
+		conn.mu.Lock()
+		policy := conn.retry
+		conn.mu.Unlock()
+		lost, ok := err.(*connectionError)
+		if !ok {
+			if err != nil || !opts.idempotent || !opts.retryStatuses[rsp.Status()] {
+				return rsp, err
+			}
+			if policy == nil {
+				policy = &DefaultStatusRetryPolicy
+			}
+			if attempt >= policy.MaxRetries {
+				return rsp, nil
+			}
+			time.Sleep(policy.backoff(attempt))
+			continue
+		}
+		if policy == nil {
+			return nil, err
+		}
+		if attempt >= policy.MaxRetries || (lost.sent && !opts.idempotent) {
+			// leave the Conn usable for the next call.
+			conn.reconnect()
+			return nil, err
+		}
+		time.Sleep(policy.backoff(attempt))
+		conn.reconnect()
+	}
+}
+
+// reconnect replaces a failed socket with a newly authenticated one.
+// It does nothing if the connection has not failed or was closed.
+func (conn *Conn) reconnect() error {
+	conn.reconnectMu.Lock()
+	defer conn.reconnectMu.Unlock()
+
+	conn.mu.Lock()
+	failed, closed := conn.err != nil, conn.closed
+	conn.mu.Unlock()
+	if !failed || closed {
+		return nil
+	}
+
+	conn.logf("Reconnecting.")
+	netConn, connData, err := conn.login(conn.loginMsg)
+	if err != nil {
+		conn.logf("Reconnect failed: %v", err)
+		return err
+	}
+
+	conn.writeMu.Lock()
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		conn.writeMu.Unlock()
+		netConn.Close()
+		return nil
+	}
+	old := conn.netConn
+	conn.netConn = netConn
+	conn.connData = connData
+	conn.gen++
+	conn.err = nil
+	gen := conn.gen
+	conn.readers.Add(1)
+	conn.mu.Unlock()
+	conn.writeMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	go conn.readResponses(netConn, gen)
+	conn.logf("Reconnected to host %d.", connData.hostId)
+	return nil
+}
+
+// SetReplayOnReconnect sets whether idempotent calls that are still
+// awaiting their response when the connection drops are resent once
+// it is re-established, rather than failed. This covers calls made
+// with CallAsyncIdempotent, batch Invocations marked Idempotent and
+// idempotent calls made with Call variants. The invocations are
+// resent as first written, client handles included, so their Futures
+// and Responses still correlate. Replay needs a RetryPolicy, which
+// bounds both the reconnect attempts and the times a call is resent.
+// Replay applies to calls made after it is enabled.
+func (conn *Conn) SetReplayOnReconnect(replay bool) {
+	conn.mu.Lock()
+	conn.replay = replay
+	conn.mu.Unlock()
+}
+
+// CallAsyncIdempotent is CallAsync for procedures that are safe to
+// run more than once; see SetReplayOnReconnect.
+func (conn *Conn) CallAsyncIdempotent(procedure string, params ...interface{}) (*Future, error) {
+	opts := newCallOptions()
+	opts.idempotent = true
+	return conn.send(context.Background(), procedure, params, opts, nil)
+}
+
+// replayCalls reconnects under the retry policy and resends the
+// invocations of futures, which were lost with the connection. If no
+// reconnect succeeds they fail with lost, the error that lost them.
+func (conn *Conn) replayCalls(futures []*Future, lost error) {
+	conn.mu.Lock()
+	policy := conn.retry
+	conn.mu.Unlock()
+	err := lost
+	for attempt := 0; policy != nil && attempt < policy.MaxRetries; attempt++ {
+		time.Sleep(policy.backoff(attempt))
+		conn.reconnect()
+		if err = conn.resend(futures); err == nil || err == ErrClosed {
+			break
+		}
+		err = lost
+	}
+	if err == nil {
+		return
+	}
+	atomic.AddInt64(&conn.stats.Errors, int64(len(futures)))
+	for _, future := range futures {
+		future.resolve(nil, err)
+	}
+	conn.mu.Lock()
+	conn.signalDrained()
+	conn.mu.Unlock()
+}
+
+// resend registers futures as pending again and writes their
+// invocations. It returns an error, leaving futures to the caller,
+// only if the Conn is closed or has not been reconnected.
+func (conn *Conn) resend(futures []*Future) error {
+	var dropped *lostCalls
+	conn.writeMu.Lock()
+	defer func() {
+		// failing calls may run callbacks that call into the Conn.
+		conn.writeMu.Unlock()
+		conn.failLost(dropped)
+	}()
+	conn.mu.Lock()
+	if conn.closed || conn.netConn == nil {
+		conn.mu.Unlock()
+		return ErrClosed
+	}
+	if conn.err != nil {
+		err := conn.err
+		conn.mu.Unlock()
+		return err
+	}
+	for _, future := range futures {
+		future.replays++
+		conn.pending[future.handle] = future
+	}
+	conn.updateReadDeadline()
+	gen, writeTimeout := conn.gen, conn.writeTimeout
+	conn.mu.Unlock()
+
+	conn.logf("Replaying %d calls.", len(futures))
+	if writeTimeout > 0 {
+		setWriteDeadline(conn.netConn, time.Now().Add(writeTimeout))
+		defer setWriteDeadline(conn.netConn, time.Time{})
+	}
+	for _, future := range futures {
+		conn.dumpFrame("sent", future.replay)
+		if _, err := conn.netConn.Write(future.replay); err != nil {
+			// the calls are pending, so losing the socket replays or
+			// fails them.
+			dropped = conn.lose(gen, &connectionError{err, true})
+			conn.netConn.Close()
+			return nil
+		}
+		atomic.AddInt64(&conn.stats.CallsSent, 1)
+		atomic.AddInt64(&conn.stats.BytesWritten, int64(len(future.replay)))
+	}
+	return nil
+}
@@ -0,0 +1,462 @@
+package voltdb
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dropFirstServer drops the first connection after reading one
+// invocation and echoes on every later connection.
+func dropFirstServer(t *testing.T) *testServer {
+	var connections int32
+	return newTestServer(t, func(c net.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			readTestInvocation(c)
+			return
+		}
+		echoServe(c)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &RetryPolicy{5, 10 * time.Millisecond, 50 * time.Millisecond}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for attempt, val := range expected {
+		if delay := policy.backoff(attempt); delay != val*time.Millisecond {
+			t.Errorf("backoff(%d) has %v wants %v", attempt, delay, val*time.Millisecond)
+		}
+	}
+}
+
+func TestReconnectIdempotent(t *testing.T) {
+	server := dropFirstServer(t)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	rsp, err := conn.CallIdempotent("Retried")
+	if err != nil {
+		t.Fatalf("CallIdempotent produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Retried" {
+		t.Errorf("Expected Retried have %v", v)
+	}
+}
+
+func TestReconnectNotIdempotent(t *testing.T) {
+	server := dropFirstServer(t)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	if _, err := conn.Call("Once"); err == nil {
+		t.Fatalf("Expected error for a lost, non-idempotent call")
+	}
+	if _, err := conn.Call("Again"); err != nil {
+		t.Errorf("Expected reconnected Conn, have %v", err)
+	}
+}
+
+func TestNoRetryPolicy(t *testing.T) {
+	server := dropFirstServer(t)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallIdempotent("Once"); err == nil {
+		t.Fatalf("Expected error for a lost call")
+	}
+	if _, err := conn.Call("Again"); err == nil {
+		t.Errorf("Expected lost Conn to stay failed without a RetryPolicy")
+	}
+}
+
+func TestNoReconnectAfterClose(t *testing.T) {
+	server := dropFirstServer(t)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	conn.Close()
+	if _, err := conn.CallIdempotent("Closed"); err == nil {
+		t.Errorf("Expected error calling on closed Conn")
+	}
+}
+
+// namedServer answers every invocation with name.
+func namedServer(t *testing.T, name string) *testServer {
+	return newTestServer(t, func(c net.Conn) {
+		for {
+			_, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(name))
+		}
+	})
+}
+
+// deadAddr returns an address with nothing listening on it.
+func deadAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func responder(t *testing.T, conn *Conn) string {
+	rsp, err := conn.Call("Who")
+	if err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	name, _, _ := table.GetString(0)
+	return name
+}
+
+func TestConnectClusterSkipsDeadHost(t *testing.T) {
+	live := namedServer(t, "live")
+	defer live.close()
+
+	conn, err := ConnectCluster([]string{deadAddr(t), live.addr()}, "user", "")
+	if err != nil {
+		t.Fatalf("ConnectCluster produced error %v", err)
+	}
+	defer conn.Close()
+	if name := responder(t, conn); name != "live" {
+		t.Errorf("Expected live host to answer, have %v", name)
+	}
+}
+
+func TestConnectClusterFailover(t *testing.T) {
+	first := namedServer(t, "first")
+	second := namedServer(t, "second")
+	defer second.close()
+
+	conn, err := ConnectCluster([]string{first.addr(), second.addr()}, "user", "")
+	if err != nil {
+		t.Fatalf("ConnectCluster produced error %v", err)
+	}
+	defer conn.Close()
+	if name := responder(t, conn); name != "first" {
+		t.Errorf("Expected first host to answer, have %v", name)
+	}
+
+	first.close()
+	conn.netConn.Close()
+	for !conn.failed() {
+		time.Sleep(time.Millisecond)
+	}
+	if name := responder(t, conn); name != "second" {
+		t.Errorf("Expected failover to second host, have %v", name)
+	}
+}
+
+func TestConnectClusterAllDead(t *testing.T) {
+	if _, err := ConnectCluster([]string{deadAddr(t), deadAddr(t)}, "user", ""); err == nil {
+		t.Errorf("Expected error when no host is reachable")
+	}
+}
+
+func TestHandlesUniqueAcrossReconnect(t *testing.T) {
+	server := dropFirstServer(t)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	seen := make(map[int64]bool)
+	for i := 0; i < 5; i++ {
+		future, err := conn.CallAsync("Unique")
+		if err != nil {
+			t.Fatalf("CallAsync produced error %v", err)
+		}
+		if seen[future.Handle()] {
+			t.Errorf("Handle %v reused", future.Handle())
+		}
+		seen[future.Handle()] = true
+		if _, err := future.Get(); err != nil {
+			// the first connection is dropped.
+			if err := conn.reconnect(); err != nil {
+				t.Fatalf("reconnect produced error %v", err)
+			}
+		}
+	}
+}
+
+func TestHandleGenerator(t *testing.T) {
+	handles := make(chan int64, 3)
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			handles <- handle
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	next := int64(1000)
+	conn.SetHandleGenerator(func() int64 {
+		next += 10
+		return next
+	})
+	for _, expected := range []int64{1010, 1020} {
+		if _, err := conn.Call("Generated"); err != nil {
+			t.Fatalf("Call produced error %v", err)
+		}
+		if handle := <-handles; handle != expected {
+			t.Errorf("Handle has %v wants %v", handle, expected)
+		}
+	}
+
+	conn.SetHandleGenerator(func() int64 { return 7 })
+	conn.mu.Lock()
+	conn.pending[7] = &Future{handle: 7, done: make(chan struct{})}
+	conn.mu.Unlock()
+	if _, err := conn.CallAsync("Duplicate"); err == nil {
+		t.Errorf("Expected error for a handle already in use")
+	}
+}
+
+// gracefulFailServer answers the first failures invocations of proc
+// with GRACEFUL_FAILURE and echoes the rest. calls counts invocations.
+func gracefulFailServer(t *testing.T, failures int32, calls *int32, proc string) *MockServer {
+	server := newMockServer(t)
+	server.Handle(proc, func([]interface{}) *MockResponse {
+		if atomic.AddInt32(calls, 1) <= failures {
+			return &MockResponse{Status: GRACEFUL_FAILURE}
+		}
+		return echoResponse(proc)
+	})
+	return server
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	var calls int32
+	server := gracefulFailServer(t, 1, &calls, "Rejoin")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	rsp, err := conn.CallWithOptions("Rejoin", nil, Idempotent(), RetryOnStatus(GRACEFUL_FAILURE))
+	if err != nil {
+		t.Fatalf("CallWithOptions produced error %v", err)
+	}
+	if rsp.Status() != SUCCESS || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Bad status %v after %d calls", rsp.Status(), calls)
+	}
+}
+
+func TestRetryOnStatusNotIdempotent(t *testing.T) {
+	var calls int32
+	server := gracefulFailServer(t, 1, &calls, "Once")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	rsp, err := conn.CallWithOptions("Once", nil, RetryOnStatus(GRACEFUL_FAILURE))
+	if err != nil {
+		t.Fatalf("CallWithOptions produced error %v", err)
+	}
+	if rsp.Status() != GRACEFUL_FAILURE || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected one GRACEFUL_FAILURE call, have %v after %d calls", rsp.Status(), calls)
+	}
+}
+
+func TestRetryOnStatusExhausted(t *testing.T) {
+	var calls int32
+	server := gracefulFailServer(t, 10, &calls, "Failing")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{2, time.Millisecond, time.Millisecond})
+	rsp, err := conn.CallWithOptions("Failing", nil, Idempotent(), RetryOnStatus(GRACEFUL_FAILURE))
+	if err != nil {
+		t.Fatalf("CallWithOptions produced error %v", err)
+	}
+	if rsp.Status() != GRACEFUL_FAILURE || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected GRACEFUL_FAILURE after 3 calls, have %v after %d calls", rsp.Status(), calls)
+	}
+}
+
+func TestWithQueryTimeout(t *testing.T) {
+	frames := make(chan []byte, 2)
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			frame, err := readFrame(c, maxMessageSize)
+			if err != nil {
+				return
+			}
+			frames <- frame
+			buf := bytes.NewBuffer(frame[messageHeaderSize:])
+			if frame[4] == invocationBatchTimeout {
+				readBatchTimeout(buf)
+			}
+			readString(buf)
+			handle, _ := readLong(buf)
+			writeTestResponse(c, handle, int8(SUCCESS))
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.CallWithOptions("Slow", nil, WithQueryTimeout(1500)); err != nil {
+		t.Fatalf("CallWithOptions produced error %v", err)
+	}
+	// the override follows the version byte, ahead of the procedure.
+	var expected bytes.Buffer
+	writeByte(&expected, invocationBatchTimeout)
+	writeByte(&expected, 1)
+	writeInt(&expected, 1500)
+	writeString(&expected, "Slow")
+	if frame := <-frames; !bytes.HasPrefix(frame[4:], expected.Bytes()) {
+		t.Errorf("Bad invocation have %x wants prefix %x", frame[4:], expected.Bytes())
+	}
+
+	if _, err := conn.Call("Fast"); err != nil {
+		t.Fatalf("Call produced error %v", err)
+	}
+	expected.Reset()
+	writeByte(&expected, invocationOriginal)
+	writeString(&expected, "Fast")
+	if frame := <-frames; !bytes.HasPrefix(frame[4:], expected.Bytes()) {
+		t.Errorf("Bad invocation have %x wants prefix %x", frame[4:], expected.Bytes())
+	}
+}
+
+// replayServer drops the first connection after reading calls
+// invocations, sending each handle read on first, and echoes on
+// every later connection, sending each handle it answers on replayed.
+func replayServer(t *testing.T, calls int, first, replayed chan<- int64) *testServer {
+	var connections int32
+	return newTestServer(t, func(c net.Conn) {
+		dropped := atomic.AddInt32(&connections, 1) == 1
+		for i := 0; !dropped || i < calls; i++ {
+			proc, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			if dropped {
+				first <- handle
+				continue
+			}
+			replayed <- handle
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+		}
+	})
+}
+
+func TestReplayOnReconnect(t *testing.T) {
+	first, replayed := make(chan int64, 3), make(chan int64, 3)
+	server := replayServer(t, 3, first, replayed)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	conn.SetReplayOnReconnect(true)
+	calls := []Invocation{
+		{Procedure: "First", Idempotent: true},
+		{Procedure: "Second"},
+		{Procedure: "Third", Idempotent: true},
+	}
+	rsps, err := conn.CallBatch(calls)
+	if _, ok := err.(*connectionError); !ok {
+		t.Errorf("Expected the connection error of Second have %v", err)
+	}
+	handles := []int64{<-first, <-first, <-first}
+	for idx, proc := range []string{"First", "", "Third"} {
+		rsp := rsps[idx]
+		if proc == "" {
+			if rsp != nil {
+				t.Errorf("Expected no response to %v have %v", calls[idx].Procedure, rsp)
+			}
+			continue
+		}
+		if rsp == nil {
+			t.Errorf("Expected %v to be replayed", proc)
+			continue
+		}
+		table := rsp.Table(0)
+		table.AdvanceRow()
+		if v, _, _ := table.GetString(0); v != proc || rsp.ClientHandle() != handles[idx] {
+			t.Errorf("Bad replayed response have %v, handle %d wants %v, handle %d",
+				v, rsp.ClientHandle(), proc, handles[idx])
+		}
+	}
+	// only the idempotent calls were sent again.
+	if n := len(replayed); n != 2 {
+		t.Errorf("Bad replay count have %v wants 2", n)
+	}
+}
+
+func TestReplayOnReconnectDisabled(t *testing.T) {
+	first, replayed := make(chan int64, 1), make(chan int64, 1)
+	server := replayServer(t, 1, first, replayed)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	future, err := conn.CallAsyncIdempotent("Lost")
+	if err != nil {
+		t.Fatalf("CallAsyncIdempotent produced error %v", err)
+	}
+	if _, err := future.Get(); err == nil {
+		t.Errorf("Expected the call to fail without replay")
+	}
+}
@@ -0,0 +1,194 @@
+package voltdb
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Row is one decoded row of a Table. Unlike the Table Get methods,
+// its accessors do not depend on the table's current row, so Rows
+// can be kept and read in any order. The bool returned by each Get
+// method is true when the column is SQL NULL.
+type Row struct {
+	columnTypes []int8
+	columnNames []string
+	values      []interface{} // nil for NULL
+}
+
+// Rows decodes the rows not yet read by AdvanceRow, without advancing
+// the table, so that it can pick up where AdvanceRow left off; see
+// SumColumn for a total over every row. Every value is decoded up
+// front, so Rows suits small results; large ones are better read with
+// AdvanceRow.
+func (table *Table) Rows() ([]Row, error) {
+	var rows []Row
+	unread := table.unreadRows()
+	for unread.AdvanceRow() {
+		values := make([]interface{}, len(table.columnTypes))
+		for idx := range values {
+			var err error
+			if values[idx], err = unread.value(idx); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, Row{table.columnTypes, table.columnNames, values})
+	}
+	if err := unread.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ToMaps decodes the rows not yet read by AdvanceRow as Rows does,
+// unlike SumColumn leaving out rows already read, and returns each as
+// a map from column name to the value Row.Value returns, nil for
+// NULL. Of columns sharing a name, the last wins.
+func (table *Table) ToMaps() ([]map[string]interface{}, error) {
+	rows, err := table.Rows()
+	if err != nil {
+		return nil, err
+	}
+	maps := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		m := make(map[string]interface{}, len(row.values))
+		for idx, name := range row.columnNames {
+			m[name] = row.values[idx]
+		}
+		maps[i] = m
+	}
+	return maps, nil
+}
+
+// ColumnIndex returns the index of the column named name, matched
+// case-insensitively.
+func (row Row) ColumnIndex(name string) (int, error) {
+	for idx, cn := range row.columnNames {
+		if strings.EqualFold(cn, name) {
+			return idx, nil
+		}
+	}
+	return -1, fmt.Errorf("No column named %v.", name)
+}
+
+// Value returns the value of column colIndex as the Go type its Get
+// method returns, or nil if it is SQL NULL.
+func (row Row) Value(colIndex int) (interface{}, error) {
+	if colIndex < 0 || colIndex >= len(row.values) {
+		return nil, fmt.Errorf("Column index %d out of range.", colIndex)
+	}
+	return row.values[colIndex], nil
+}
+
+// value returns the value of column colIndex after checking that the
+// column has type vt.
+func (row Row) value(colIndex int, vt int8) (interface{}, error) {
+	if colIndex < 0 || colIndex >= len(row.values) {
+		return nil, fmt.Errorf("Column index %d out of range.", colIndex)
+	}
+	if row.columnTypes[colIndex] != vt {
+		return nil, protocolError(ErrUnexpectedType, "Column %d has type %d not %d.",
+			colIndex, row.columnTypes[colIndex], vt)
+	}
+	return row.values[colIndex], nil
+}
+
+// GetByte returns the TINYINT value of column colIndex.
+func (row Row) GetByte(colIndex int) (int8, bool, error) {
+	val, err := row.value(colIndex, vt_TINYINT)
+	if err != nil || val == nil {
+		return 0, err == nil, err
+	}
+	return val.(int8), false, nil
+}
+
+// GetShort returns the SMALLINT value of column colIndex.
+func (row Row) GetShort(colIndex int) (int16, bool, error) {
+	val, err := row.value(colIndex, vt_SHORT)
+	if err != nil || val == nil {
+		return 0, err == nil, err
+	}
+	return val.(int16), false, nil
+}
+
+// GetInt returns the INTEGER value of column colIndex.
+func (row Row) GetInt(colIndex int) (int32, bool, error) {
+	val, err := row.value(colIndex, vt_INT)
+	if err != nil || val == nil {
+		return 0, err == nil, err
+	}
+	return val.(int32), false, nil
+}
+
+// GetLong returns the BIGINT value of column colIndex.
+func (row Row) GetLong(colIndex int) (int64, bool, error) {
+	val, err := row.value(colIndex, vt_LONG)
+	if err != nil || val == nil {
+		return 0, err == nil, err
+	}
+	return val.(int64), false, nil
+}
+
+// GetFloat returns the FLOAT value of column colIndex.
+func (row Row) GetFloat(colIndex int) (float64, bool, error) {
+	val, err := row.value(colIndex, vt_FLOAT)
+	if err != nil || val == nil {
+		return 0, err == nil, err
+	}
+	return val.(float64), false, nil
+}
+
+// GetString returns the STRING value of column colIndex.
+func (row Row) GetString(colIndex int) (string, bool, error) {
+	val, err := row.value(colIndex, vt_STRING)
+	if err != nil || val == nil {
+		return "", err == nil, err
+	}
+	return val.(string), false, nil
+}
+
+// GetVarbinary returns the VARBINARY value of column colIndex.
+func (row Row) GetVarbinary(colIndex int) ([]byte, bool, error) {
+	val, err := row.value(colIndex, vt_VARBIN)
+	if err != nil || val == nil {
+		return nil, err == nil, err
+	}
+	return val.([]byte), false, nil
+}
+
+// GetTimestamp returns the TIMESTAMP value of column colIndex in UTC.
+func (row Row) GetTimestamp(colIndex int) (time.Time, bool, error) {
+	val, err := row.value(colIndex, vt_TIMESTAMP)
+	if err != nil || val == nil {
+		return time.Time{}, err == nil, err
+	}
+	return val.(time.Time), false, nil
+}
+
+// GetDecimal returns the DECIMAL value of column colIndex.
+func (row Row) GetDecimal(colIndex int) (*big.Rat, bool, error) {
+	val, err := row.value(colIndex, vt_DECIMAL)
+	if err != nil || val == nil {
+		return nil, err == nil, err
+	}
+	return val.(*big.Rat), false, nil
+}
+
+// GetPoint returns the GEOGRAPHY_POINT value of column colIndex.
+func (row Row) GetPoint(colIndex int) (GeographyPoint, bool, error) {
+	val, err := row.value(colIndex, vt_POINT)
+	if err != nil || val == nil {
+		return GeographyPoint{}, err == nil, err
+	}
+	return val.(GeographyPoint), false, nil
+}
+
+// GetGeography returns the GEOGRAPHY value of column colIndex.
+func (row Row) GetGeography(colIndex int) (*Geography, bool, error) {
+	val, err := row.value(colIndex, vt_GEOGRAPHY)
+	if err != nil || val == nil {
+		return nil, err == nil, err
+	}
+	return val.(*Geography), false, nil
+}
@@ -0,0 +1,97 @@
+package voltdb
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRows(t *testing.T) {
+	ts := time.Date(2018, 5, 6, 7, 8, 9, 0, time.UTC)
+	table := newTestTable(t,
+		[]testColumn{{"ID", vt_INT}, {"NAME", vt_STRING}, {"TS", vt_TIMESTAMP},
+			{"PRICE", vt_DECIMAL}, {"DATA", vt_VARBIN}},
+		[][]interface{}{
+			{int32(1), "one", ts, big.NewRat(5, 2), []byte{1, 2}},
+			{int32(2), nil, nil, nil, nil},
+		})
+	rows, err := table.Rows()
+	if err != nil {
+		t.Fatalf("Rows produced error %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Bad row count have %v wants 2", len(rows))
+	}
+	if table.RowCount() != 2 || !table.AdvanceRow() {
+		t.Errorf("Rows advanced the table")
+	}
+
+	// rows are read out of order to show they are independent.
+	second, first := rows[1], rows[0]
+	if v, isNull, err := second.GetInt(0); v != 2 || isNull || err != nil {
+		t.Errorf("Bad GetInt have %v, %v, %v", v, isNull, err)
+	}
+	if _, isNull, err := second.GetString(1); !isNull || err != nil {
+		t.Errorf("Expected NULL string have %v, %v", isNull, err)
+	}
+	if _, isNull, err := second.GetDecimal(3); !isNull || err != nil {
+		t.Errorf("Expected NULL decimal have %v, %v", isNull, err)
+	}
+	if v, isNull, err := first.GetInt(0); v != 1 || isNull || err != nil {
+		t.Errorf("Bad GetInt have %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := first.GetString(1); v != "one" || isNull || err != nil {
+		t.Errorf("Bad GetString have %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := first.GetTimestamp(2); !v.Equal(ts) || isNull || err != nil {
+		t.Errorf("Bad GetTimestamp have %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := first.GetDecimal(3); v == nil || v.Cmp(big.NewRat(5, 2)) != 0 || isNull || err != nil {
+		t.Errorf("Bad GetDecimal have %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := first.GetVarbinary(4); len(v) != 2 || v[1] != 2 || isNull || err != nil {
+		t.Errorf("Bad GetVarbinary have %v, %v, %v", v, isNull, err)
+	}
+	if idx, err := first.ColumnIndex("price"); idx != 3 || err != nil {
+		t.Errorf("Bad ColumnIndex have %v, %v", idx, err)
+	}
+	if v, err := first.Value(1); v != "one" || err != nil {
+		t.Errorf("Bad Value have %v, %v", v, err)
+	}
+
+	if _, _, err := first.GetLong(0); err == nil {
+		t.Errorf("Expected error reading INTEGER as BIGINT")
+	}
+	if _, _, err := first.GetInt(5); err == nil {
+		t.Errorf("Expected error for out of range column")
+	}
+	if _, err := first.ColumnIndex("missing"); err == nil {
+		t.Errorf("Expected error for unknown column")
+	}
+}
+
+func TestToMaps(t *testing.T) {
+	ts := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	table := newTestTable(t,
+		[]testColumn{{"ID", vt_INT}, {"COUNT", vt_LONG}, {"NAME", vt_STRING},
+			{"SCORE", vt_FLOAT}, {"TS", vt_TIMESTAMP}, {"DATA", vt_VARBIN}},
+		[][]interface{}{
+			{int32(1), int64(10), "one", 1.5, ts, []byte{1, 2}},
+			{int32(2), nil, nil, nil, nil, nil},
+		})
+	maps, err := table.ToMaps()
+	if err != nil {
+		t.Fatalf("ToMaps produced error %v", err)
+	}
+	expected := []map[string]interface{}{
+		{"ID": int32(1), "COUNT": int64(10), "NAME": "one", "SCORE": 1.5, "TS": ts, "DATA": []byte{1, 2}},
+		{"ID": int32(2), "COUNT": nil, "NAME": nil, "SCORE": nil, "TS": nil, "DATA": nil},
+	}
+	if !reflect.DeepEqual(maps, expected) {
+		t.Errorf("Bad maps have %v wants %v", maps, expected)
+	}
+	if table.RowCount() != 2 || !table.AdvanceRow() {
+		t.Errorf("ToMaps advanced the table")
+	}
+}
@@ -0,0 +1,80 @@
+package voltdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// selftest.go checks at startup that the serialization helpers write
+// the big-endian bytes VoltDB expects and read them back, so that a
+// broken helper panics at once rather than corrupting traffic.
+
+// serializerCheck is one known value, its wire bytes and the helpers
+// that convert between them.
+type serializerCheck struct {
+	name  string
+	val   interface{}
+	wire  []byte
+	write func(w io.Writer, val interface{}) error
+	read  func(r io.Reader) (interface{}, error)
+}
+
+var serializerChecks = []serializerCheck{
+	{"byte", int8(-2), []byte{0xFE},
+		func(w io.Writer, val interface{}) error { return writeByte(w, val.(int8)) },
+		func(r io.Reader) (interface{}, error) { return readByte(r) }},
+	{"short", int16(0x0102), []byte{0x01, 0x02},
+		func(w io.Writer, val interface{}) error { return writeShort(w, val.(int16)) },
+		func(r io.Reader) (interface{}, error) { return readShort(r) }},
+	{"int", int32(0x01020304), []byte{0x01, 0x02, 0x03, 0x04},
+		func(w io.Writer, val interface{}) error { return writeInt(w, val.(int32)) },
+		func(r io.Reader) (interface{}, error) { return readInt(r) }},
+	{"long", int64(0x0102030405060708), []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		func(w io.Writer, val interface{}) error { return writeLong(w, val.(int64)) },
+		func(r io.Reader) (interface{}, error) { return readLong(r) }},
+	{"float", 1.5, []byte{0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(w io.Writer, val interface{}) error { return writeFloat(w, val.(float64)) },
+		func(r io.Reader) (interface{}, error) { return readFloat(r) }},
+	{"string", "ab", []byte{0x00, 0x00, 0x00, 0x02, 'a', 'b'},
+		func(w io.Writer, val interface{}) error { return writeString(w, val.(string)) },
+		func(r io.Reader) (interface{}, error) { return readString(r) }},
+	{"timestamp", time.UnixMicro(0x0102).UTC(), []byte{0, 0, 0, 0, 0, 0, 0x01, 0x02},
+		func(w io.Writer, val interface{}) error { return writeTimestamp(w, val.(time.Time)) },
+		func(r io.Reader) (interface{}, error) { return readTimestamp(r) }},
+	{"decimal", big.NewRat(1, 1000000000000), []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01},
+		func(w io.Writer, val interface{}) error { return writeDecimal(w, val.(*big.Rat)) },
+		func(r io.Reader) (interface{}, error) { return readDecimal(r) }},
+}
+
+func init() {
+	if err := checkSerializers(serializerChecks); err != nil {
+		panic("voltdb: serializer self-test failed: " + err.Error())
+	}
+}
+
+// checkSerializers writes and reads back the value of each check,
+// returning an error for the first that does not round trip through
+// its expected wire bytes.
+func checkSerializers(checks []serializerCheck) error {
+	var b bytes.Buffer
+	for _, check := range checks {
+		b.Reset()
+		if err := check.write(&b, check.val); err != nil {
+			return fmt.Errorf("%v write: %v", check.name, err)
+		}
+		if !bytes.Equal(b.Bytes(), check.wire) {
+			return fmt.Errorf("%v wrote %x wants %x", check.name, b.Bytes(), check.wire)
+		}
+		val, err := check.read(&b)
+		if err != nil {
+			return fmt.Errorf("%v read: %v", check.name, err)
+		}
+		if fmt.Sprint(val) != fmt.Sprint(check.val) || b.Len() != 0 {
+			return fmt.Errorf("%v read %v wants %v", check.name, val, check.val)
+		}
+	}
+	return nil
+}
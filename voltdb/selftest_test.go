@@ -0,0 +1,36 @@
+package voltdb
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestSerializerSelfTest(t *testing.T) {
+	if err := checkSerializers(serializerChecks); err != nil {
+		t.Errorf("Self-test produced error %v", err)
+	}
+}
+
+func TestSerializerSelfTestCatchesBrokenHelper(t *testing.T) {
+	littleEndianFloat := func(w io.Writer, val interface{}) error {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(val.(float64)))
+		_, err := w.Write(b[:])
+		return err
+	}
+	truncatingInt := func(r io.Reader) (interface{}, error) {
+		v, err := readInt(r)
+		return int32(int16(v)), err
+	}
+	checks := []serializerCheck{serializerChecks[4], serializerChecks[2]}
+	checks[0].write = littleEndianFloat
+	if err := checkSerializers(checks[:1]); err == nil {
+		t.Errorf("Expected error for a little-endian float writer")
+	}
+	checks[1].read = truncatingInt
+	if err := checkSerializers(checks[1:]); err == nil {
+		t.Errorf("Expected error for a truncating int reader")
+	}
+}
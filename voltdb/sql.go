@@ -0,0 +1,258 @@
+package voltdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// sql.go adapts Conn to the database/sql package under the driver
+// name "voltdb". A query is the name of a stored procedure and its
+// arguments are the procedure parameters, in order. The procedure
+// name may be followed by a placeholder list:
+//
+//	db, _ := sql.Open("voltdb", "username:password@localhost:21212")
+//	rows, _ := db.Query("Results")
+//	rows, _ = db.Query("Vote(?, ?, ?)", phoneNumber, contestant, 100)
+//
+// VoltDB transactions are stored procedures, so Begin is not supported.
+
+func init() {
+	sql.Register("voltdb", &Driver{})
+}
+
+// Driver is the database/sql driver for VoltDB. Data source names
+// have the form "user:password@host:port"; user and password may be
+// omitted.
+type Driver struct{}
+
+// Open returns a new connection to the database named by dsn.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector parses dsn once for use by every new connection.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	user, passwd, hostAndPort := parseDSN(dsn)
+	if hostAndPort == "" {
+		return nil, fmt.Errorf("Missing host in data source name %v.", dsn)
+	}
+	return &connector{d, user, passwd, hostAndPort}, nil
+}
+
+// parseDSN splits "user:password@host:port" into its parts.
+func parseDSN(dsn string) (user, passwd, hostAndPort string) {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return "", "", dsn
+	}
+	hostAndPort = dsn[at+1:]
+	user = dsn[:at]
+	if colon := strings.Index(user, ":"); colon >= 0 {
+		user, passwd = user[:colon], user[colon+1:]
+	}
+	return
+}
+
+type connector struct {
+	driver      *Driver
+	user        string
+	passwd      string
+	hostAndPort string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := NewConnection(c.user, c.passwd, c.hostAndPort)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{conn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+type sqlConn struct {
+	conn *Conn
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	procedure, numInput := parseQuery(query)
+	if procedure == "" {
+		return nil, fmt.Errorf("Missing procedure name in query %v.", query)
+	}
+	return &sqlStmt{c.conn, procedure, numInput}, nil
+}
+
+// parseQuery splits "Procedure(?, ?)" into the procedure name and
+// placeholder count. Without a placeholder list the count is -1.
+func parseQuery(query string) (procedure string, numInput int) {
+	query = strings.TrimSpace(query)
+	open := strings.Index(query, "(")
+	if open < 0 || !strings.HasSuffix(query, ")") {
+		return query, -1
+	}
+	return strings.TrimSpace(query[:open]), strings.Count(query[open:], "?")
+}
+
+func (c *sqlConn) Close() error {
+	return c.conn.Close()
+}
+
+// IsValid reports whether the connection can still be used, so that
+// database/sql discards one whose socket has been lost.
+func (c *sqlConn) IsValid() bool {
+	return !c.conn.failed()
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("Transactions are not supported. Use a stored procedure.")
+}
+
+type sqlStmt struct {
+	conn      *Conn
+	procedure string
+	numInput  int
+}
+
+func (s *sqlStmt) Close() error {
+	return nil
+}
+
+func (s *sqlStmt) NumInput() int {
+	return s.numInput
+}
+
+func (s *sqlStmt) call(args []driver.Value) (*Response, error) {
+	params := make([]interface{}, len(args))
+	for idx, arg := range args {
+		params[idx] = arg
+	}
+	rsp, err := s.conn.Call(s.procedure, params...)
+	if err != nil {
+		return nil, badConn(err)
+	}
+	if rsp.Status() != SUCCESS {
+		return nil, fmt.Errorf("%v failed: %v %v", s.procedure,
+			rsp.Status(), rsp.StatusString())
+	}
+	return rsp, nil
+}
+
+// badConn returns driver.ErrBadConn for a call that failed, unsent,
+// because the connection was lost, so that database/sql retries it on
+// another connection. A call that may have reached the server is not
+// retried.
+func badConn(err error) error {
+	if lost, ok := err.(*connectionError); ok && !lost.sent {
+		return driver.ErrBadConn
+	}
+	return err
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rsp, err := s.call(args)
+	if err != nil {
+		return nil, err
+	}
+	count, err := modifiedRows(rsp)
+	return sqlResult{count, err}, nil
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rsp, err := s.call(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp.tables) == 0 {
+		return nil, fmt.Errorf("%v returned no tables.", s.procedure)
+	}
+	return &sqlRows{rsp.Table(0)}, nil
+}
+
+// sqlResult reports the modified row count that VoltDB returns as
+// a single row, single BIGINT column table. err is set if the
+// response held no such count.
+type sqlResult struct {
+	count int64
+	err   error
+}
+
+func (r sqlResult) LastInsertId() (int64, error) {
+	return 0, fmt.Errorf("LastInsertId is not supported.")
+}
+
+func (r sqlResult) RowsAffected() (int64, error) {
+	return r.count, r.err
+}
+
+// modifiedRows reads the modified row count of rsp, which is zero if
+// rsp has no tables.
+func modifiedRows(rsp *Response) (int64, error) {
+	if len(rsp.tables) == 0 {
+		return 0, nil
+	}
+	table := rsp.Table(0)
+	if table.RowCount() != 1 || table.ColumnCount() != 1 || !table.AdvanceRow() {
+		return 0, fmt.Errorf("Result is not a modified row count.")
+	}
+	count, _, err := table.GetLong(0)
+	return count, err
+}
+
+type sqlRows struct {
+	table *Table
+}
+
+func (r *sqlRows) Columns() []string {
+	return r.table.ColumnNames()
+}
+
+func (r *sqlRows) Close() error {
+	return nil
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	if !r.table.AdvanceRow() {
+		if err := r.table.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	for idx := range dest {
+		val, err := r.table.value(idx)
+		if err != nil {
+			return err
+		}
+		switch v := val.(type) {
+		case int8:
+			dest[idx] = int64(v)
+		case int16:
+			dest[idx] = int64(v)
+		case int32:
+			dest[idx] = int64(v)
+		case *big.Rat:
+			dest[idx] = v.FloatString(decimalScale)
+		case GeographyPoint:
+			// not a driver.Value type, so passed as well-known text.
+			dest[idx] = v.String()
+		case *Geography:
+			dest[idx] = v.String()
+		default:
+			dest[idx] = v
+		}
+	}
+	return nil
+}
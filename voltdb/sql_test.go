@@ -0,0 +1,201 @@
+package voltdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseDSN(t *testing.T) {
+	testVals := map[string][3]string{
+		"user:pass@localhost:21212": {"user", "pass", "localhost:21212"},
+		"user@localhost:21212":      {"user", "", "localhost:21212"},
+		"localhost:21212":           {"", "", "localhost:21212"},
+		"user:p@ss@localhost:21212": {"user", "p@ss", "localhost:21212"},
+	}
+	for dsn, expected := range testVals {
+		user, passwd, hostAndPort := parseDSN(dsn)
+		if user != expected[0] || passwd != expected[1] || hostAndPort != expected[2] {
+			t.Errorf("parseDSN(%v) has %v, %v, %v wants %v",
+				dsn, user, passwd, hostAndPort, expected)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	if proc, n := parseQuery("Results"); proc != "Results" || n != -1 {
+		t.Errorf("Bad parseQuery %v, %v", proc, n)
+	}
+	if proc, n := parseQuery(" Vote(?, ?, ?) "); proc != "Vote" || n != 3 {
+		t.Errorf("Bad parseQuery %v, %v", proc, n)
+	}
+	if proc, n := parseQuery("Initialize()"); proc != "Initialize" || n != 0 {
+		t.Errorf("Bad parseQuery %v, %v", proc, n)
+	}
+}
+
+func TestSQLQuery(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			proc, handle, params, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			var table bytes.Buffer
+			if paramCount, _ := readShort(params); proc == "Results" && paramCount == 1 {
+				writeTestTable(&table, -128,
+					[]testColumn{{"ID", vt_INT}, {"NAME", vt_STRING}},
+					[][]interface{}{{int32(1), "one"}, {int32(2), nil}})
+			} else if proc == "Insert" && paramCount == 2 {
+				writeTestTable(&table, -128, []testColumn{{"MODIFIED", vt_LONG}},
+					[][]interface{}{{int64(1)}})
+			} else {
+				writeTestResponse(c, handle, int8(GRACEFUL_FAILURE))
+				continue
+			}
+			writeTestResponse(c, handle, int8(SUCCESS), table.Bytes())
+		}
+	})
+	defer server.close()
+
+	db, err := sql.Open("voltdb", "user:@"+server.addr())
+	if err != nil {
+		t.Fatalf("sql.Open produced error %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("Results(?)", "all")
+	if err != nil {
+		t.Fatalf("Query produced error %v", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	var names []sql.NullString
+	for rows.Next() {
+		var id int64
+		var name sql.NullString
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatalf("Scan produced error %v", err)
+		}
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Bad ids %v", ids)
+	}
+	if len(names) != 2 || names[0].String != "one" || names[1].Valid {
+		t.Errorf("Bad names %v", names)
+	}
+
+	result, err := db.Exec("Insert(?, ?)", 1, "one")
+	if err != nil {
+		t.Fatalf("Exec produced error %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := result.RowsAffected(); n != 1 || err != nil {
+			t.Errorf("Bad RowsAffected %v, %v", n, err)
+		}
+	}
+
+	if _, err := db.Exec("Missing"); err == nil {
+		t.Errorf("Expected error from failed procedure")
+	}
+}
+
+func TestSQLQueryGeography(t *testing.T) {
+	ring := []GeographyPoint{{0, 0}, {1, 0}, {0, 1}, {0, 0}}
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			_, handle, _, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			var table bytes.Buffer
+			writeTestTable(&table, -128,
+				[]testColumn{{"P", vt_POINT}, {"G", vt_GEOGRAPHY}},
+				[][]interface{}{
+					{GeographyPoint{-71.5, 42.25}, &Geography{Rings: [][]GeographyPoint{ring}}},
+					{nil, nil},
+				})
+			writeTestResponse(c, handle, int8(SUCCESS), table.Bytes())
+		}
+	})
+	defer server.close()
+
+	db, err := sql.Open("voltdb", "user:@"+server.addr())
+	if err != nil {
+		t.Fatalf("sql.Open produced error %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("Places")
+	if err != nil {
+		t.Fatalf("Query produced error %v", err)
+	}
+	defer rows.Close()
+	var points, polygons []sql.NullString
+	for rows.Next() {
+		var point, polygon sql.NullString
+		if err := rows.Scan(&point, &polygon); err != nil {
+			t.Fatalf("Scan produced error %v", err)
+		}
+		points = append(points, point)
+		polygons = append(polygons, polygon)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Rows produced error %v", err)
+	}
+	if len(points) != 2 || points[0].String != "POINT (-71.5 42.25)" || points[1].Valid {
+		t.Errorf("Bad points %v", points)
+	}
+	expected := (&Geography{Rings: [][]GeographyPoint{ring}}).String()
+	if len(polygons) != 2 || polygons[0].String != expected || polygons[1].Valid {
+		t.Errorf("Bad polygons have %v wants %v", polygons, expected)
+	}
+}
+
+func TestSQLBadConn(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	db, err := sql.Open("voltdb", "user:@"+server.addr())
+	if err != nil {
+		t.Fatalf("sql.Open produced error %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// lose the socket of the only connection in the pool.
+	c, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn produced error %v", err)
+	}
+	err = c.Raw(func(dc interface{}) error {
+		conn := dc.(*sqlConn)
+		conn.conn.netConn.Close()
+		for !conn.conn.failed() {
+			time.Sleep(time.Millisecond)
+		}
+		if conn.IsValid() {
+			t.Errorf("Expected a lost connection to be invalid")
+		}
+		stmt, _ := conn.Prepare("Lost")
+		if _, err := stmt.Exec(nil); err != driver.ErrBadConn {
+			t.Errorf("Expected driver.ErrBadConn have %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Raw produced error %v", err)
+	}
+	c.Close()
+
+	var name string
+	if err := db.QueryRow("Next").Scan(&name); name != "Next" || err != nil {
+		t.Errorf("Bad query on a new connection have %v, %v", name, err)
+	}
+}
@@ -0,0 +1,33 @@
+package voltdb
+
+import "sync/atomic"
+
+// ConnStats counts the traffic of a Conn since it was made. Bytes are
+// those of invocation and response frames, headers included; logins
+// are not counted.
+type ConnStats struct {
+	CallsSent         int64 // invocations written
+	ResponsesReceived int64 // responses read, late ones included
+	BytesWritten      int64
+	BytesRead         int64
+	// LateResponses counts responses discarded because their call
+	// had been given up on, as by CallTimeout.
+	LateResponses int64
+	// Errors counts calls that failed without a response: those send
+	// rejected or could not write, and those pending when the
+	// connection was lost or closed.
+	Errors int64
+}
+
+// Stats returns a snapshot of the counters of conn. Each counter is
+// read atomically, though not all at the same instant.
+func (conn *Conn) Stats() ConnStats {
+	return ConnStats{
+		CallsSent:         atomic.LoadInt64(&conn.stats.CallsSent),
+		ResponsesReceived: atomic.LoadInt64(&conn.stats.ResponsesReceived),
+		LateResponses:     atomic.LoadInt64(&conn.stats.LateResponses),
+		BytesWritten:      atomic.LoadInt64(&conn.stats.BytesWritten),
+		BytesRead:         atomic.LoadInt64(&conn.stats.BytesRead),
+		Errors:            atomic.LoadInt64(&conn.stats.Errors),
+	}
+}
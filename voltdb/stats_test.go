@@ -0,0 +1,64 @@
+package voltdb
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	server := newTestServer(t, echoServe)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	if stats := conn.Stats(); stats != (ConnStats{}) {
+		t.Errorf("Bad initial stats %+v", stats)
+	}
+
+	var expected ConnStats
+	for _, proc := range []string{"A", "BB", "CCC"} {
+		if _, err := conn.Call(proc, int32(7)); err != nil {
+			t.Fatalf("Call produced error %v", err)
+		}
+		call := newEncoder()
+		serializeCall(call, proc, 0, []interface{}{int32(7)})
+		var rsp bytes.Buffer
+		writeTestResponse(&rsp, 0, int8(SUCCESS), echoTable(proc))
+		expected.CallsSent++
+		expected.ResponsesReceived++
+		expected.BytesWritten += int64(call.Len())
+		expected.BytesRead += int64(rsp.Len())
+	}
+	if _, err := conn.Call(""); err == nil {
+		t.Fatalf("Expected error for empty procedure name")
+	}
+	expected.Errors++
+	if stats := conn.Stats(); stats != expected {
+		t.Errorf("Bad stats have %+v wants %+v", stats, expected)
+	}
+}
+
+func TestStatsCountsLostCalls(t *testing.T) {
+	server := newTestServer(t, func(c net.Conn) {
+		readTestInvocation(c)
+		readTestInvocation(c)
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	first, _ := conn.CallAsync("Lost")
+	second, _ := conn.CallAsync("Lost")
+	first.Get()
+	second.Get()
+	if stats := conn.Stats(); stats.CallsSent != 2 || stats.Errors != 2 || stats.ResponsesReceived != 0 {
+		t.Errorf("Bad stats after lost connection %+v", stats)
+	}
+}
@@ -0,0 +1,95 @@
+package voltdb
+
+import (
+	"fmt"
+	"io"
+)
+
+// RowStream reads the rows of a serialized table one at a time from
+// an io.Reader, holding only the current row in memory. It suits
+// result sets too large to buffer as a Table.
+type RowStream struct {
+	r         io.Reader
+	table     Table // header; row holds the current row
+	remaining int32
+	bytesLeft int64 // of the row data declared by the header
+	buf       []byte
+	err       error
+}
+
+// NewRowStream reads the table header from r. Rows are read by Next.
+func NewRowStream(r io.Reader) (*RowStream, error) {
+	table, tableByteCount, err := readTableHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &RowStream{r: r, table: table, remaining: table.rowCount, bytesLeft: tableByteCount}, nil
+}
+
+// Next reads the next row. It returns false at the end of the table
+// or on error; see Err.
+func (rs *RowStream) Next() bool {
+	rs.table.row = nil
+	if rs.err != nil || rs.remaining <= 0 {
+		return false
+	}
+	rowLength, err := readInt(rs.r)
+	if err != nil {
+		rs.err = truncated(err, "table")
+		return false
+	}
+	if rowLength < 0 || rowLength > maxMessageSize {
+		rs.err = fmt.Errorf("Bad row length %d.", rowLength)
+		return false
+	}
+	if rs.bytesLeft -= 4 + int64(rowLength); rs.bytesLeft < 0 {
+		rs.err = protocolError(ErrLengthMismatch, "Row length %d exceeds the table's row data.", rowLength)
+		return false
+	}
+	if cap(rs.buf) < int(rowLength) {
+		rs.buf = make([]byte, rowLength)
+	}
+	row := rs.buf[:rowLength]
+	if _, err = io.ReadFull(rs.r, row); err != nil {
+		rs.err = truncated(err, "row")
+		return false
+	}
+	offsets, err := columnOffsets(rs.table.columnTypes, row)
+	if err != nil {
+		rs.err = err
+		return false
+	}
+	rs.table.row = row
+	rs.table.colOffsets = offsets
+	rs.remaining--
+	if rs.remaining == 0 && rs.bytesLeft != 0 {
+		rs.err = protocolError(ErrLengthMismatch, "Table has %d bytes beyond its rows.", rs.bytesLeft)
+	}
+	return true
+}
+
+// Scan copies the columns of the current row into dest, as
+// Table.Scan does.
+func (rs *RowStream) Scan(dest ...interface{}) error {
+	return rs.table.Scan(dest...)
+}
+
+// Err returns the error that stopped Next, if any.
+func (rs *RowStream) Err() error {
+	return rs.err
+}
+
+// ColumnNames returns the names of the table's columns.
+func (rs *RowStream) ColumnNames() []string {
+	return rs.table.ColumnNames()
+}
+
+// ColumnTypes returns the types of the table's columns.
+func (rs *RowStream) ColumnTypes() []int8 {
+	return rs.table.ColumnTypes()
+}
+
+// RowCount returns the number of rows in the table.
+func (rs *RowStream) RowCount() int {
+	return rs.table.RowCount()
+}
@@ -0,0 +1,97 @@
+package voltdb
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// writeStreamTable writes a table of rows (ID INTEGER, DATA STRING)
+// to w, generating each row as it goes.
+func writeStreamTable(w io.Writer, rows int, data string) error {
+	var meta bytes.Buffer
+	writeByte(&meta, -128)
+	writeShort(&meta, 2)
+	writeByte(&meta, vt_INT)
+	writeByte(&meta, vt_STRING)
+	writeString(&meta, "ID")
+	writeString(&meta, "DATA")
+	rowLength := 4 + 4 + len(data)
+	writeInt(w, int32(meta.Len()+8+rows*(4+rowLength)))
+	writeInt(w, int32(meta.Len()))
+	w.Write(meta.Bytes())
+	writeInt(w, int32(rows))
+	var row bytes.Buffer
+	for idx := 0; idx < rows; idx++ {
+		row.Reset()
+		writeInt(&row, int32(rowLength))
+		writeInt(&row, int32(idx))
+		writeString(&row, data)
+		if _, err := w.Write(row.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRowStream(t *testing.T) {
+	const rows = 20000
+	data := string(bytes.Repeat([]byte("d"), 4096))
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeStreamTable(w, rows, data))
+	}()
+
+	stream, err := NewRowStream(r)
+	if err != nil {
+		t.Fatalf("NewRowStream produced error %v", err)
+	}
+	if stream.RowCount() != rows || len(stream.ColumnNames()) != 2 {
+		t.Fatalf("Bad header %v rows %v", stream.RowCount(), stream.ColumnNames())
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	count := 0
+	for stream.Next() {
+		var id int32
+		var s string
+		if err := stream.Scan(&id, &s); err != nil {
+			t.Fatalf("Scan produced error %v", err)
+		}
+		if int(id) != count || len(s) != len(data) {
+			t.Fatalf("Bad row %v, %v bytes", id, len(s))
+		}
+		count++
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if err := stream.Err(); err != nil {
+		t.Fatalf("Next produced error %v", err)
+	}
+	if count != rows {
+		t.Errorf("Streamed %v rows wants %v", count, rows)
+	}
+	// the table is about 80MB; only a row at a time may be held.
+	if growth := int64(after.HeapAlloc) - int64(before.HeapAlloc); growth > 4<<20 {
+		t.Errorf("Heap grew by %v bytes while streaming", growth)
+	}
+}
+
+func TestRowStreamTruncated(t *testing.T) {
+	var b bytes.Buffer
+	writeStreamTable(&b, 3, "abc")
+	stream, err := NewRowStream(bytes.NewReader(b.Bytes()[:b.Len()-2]))
+	if err != nil {
+		t.Fatalf("NewRowStream produced error %v", err)
+	}
+	count := 0
+	for stream.Next() {
+		count++
+	}
+	if count != 2 || stream.Err() == nil {
+		t.Errorf("Expected 2 rows and an error, have %v, %v", count, stream.Err())
+	}
+}
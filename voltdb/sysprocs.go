@@ -0,0 +1,172 @@
+package voltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sysprocs.go wraps the VoltDB system procedures operators call most
+// often. Selectors are matched case-insensitively and checked before
+// anything is sent to the server.
+
+var statisticsSelectors = selectorSet("COMMANDLOG", "CPU", "DR", "DRCONSUMER",
+	"DRPRODUCER", "DRROLE", "EXPORT", "GC", "IDLETIME", "IMPORT", "INDEX",
+	"INITIATOR", "IOSTATS", "LATENCY", "LIVECLIENTS", "MANAGEMENT", "MEMORY",
+	"PARTITIONCOUNT", "PLANNER", "PROCEDURE", "PROCEDUREDETAIL",
+	"PROCEDUREINPUT", "PROCEDUREOUTPUT", "PROCEDUREPROFILE", "QUEUE",
+	"REBALANCE", "SNAPSHOTSTATUS", "SNAPSHOTSUMMARY", "TABLE", "TASK", "TOPO",
+	"TTL")
+
+var systemInformationSelectors = selectorSet("OVERVIEW", "DEPLOYMENT", "LICENSE")
+
+var systemCatalogSelectors = selectorSet("CLASSES", "COLUMNS", "FUNCTIONS",
+	"INDEXINFO", "PRIMARYKEYS", "PROCEDURECOLUMNS", "PROCEDURES", "TABLES")
+
+func selectorSet(selectors ...string) map[string]bool {
+	set := make(map[string]bool, len(selectors))
+	for _, selector := range selectors {
+		set[selector] = true
+	}
+	return set
+}
+
+// Statistics calls @Statistics for selector and returns its first
+// result table. If interval is true the statistics cover the time
+// since the previous interval call rather than since startup.
+func (conn *Conn) Statistics(selector string, interval bool) (*Table, error) {
+	var delta int32
+	if interval {
+		delta = 1
+	}
+	return conn.callSysproc("@Statistics", statisticsSelectors, selector, delta)
+}
+
+// SystemInformation calls @SystemInformation for selector, such as
+// OVERVIEW or DEPLOYMENT, and returns its result table.
+func (conn *Conn) SystemInformation(selector string) (*Table, error) {
+	return conn.callSysproc("@SystemInformation", systemInformationSelectors, selector)
+}
+
+// SystemCatalog calls @SystemCatalog for selector, such as TABLES or
+// COLUMNS, and returns its result table.
+func (conn *Conn) SystemCatalog(selector string) (*Table, error) {
+	return conn.callSysproc("@SystemCatalog", systemCatalogSelectors, selector)
+}
+
+// ProcedureInfo describes a stored procedure in the catalog.
+type ProcedureInfo struct {
+	Name            string
+	SinglePartition bool
+	// PartitionParameter is the index of the parameter that picks the
+	// partition of a single partition procedure, and -1 otherwise.
+	PartitionParameter int
+	ReadOnly           bool
+}
+
+// Procedures lists the stored procedures in the catalog by calling
+// @SystemCatalog PROCEDURES.
+func (conn *Conn) Procedures() ([]ProcedureInfo, error) {
+	table, err := conn.SystemCatalog("PROCEDURES")
+	if err != nil {
+		return nil, err
+	}
+	return parseProcedures(table)
+}
+
+// parseProcedures reads a @SystemCatalog PROCEDURES table. The
+// partitioning and read-only flag are JSON in its REMARKS column.
+func parseProcedures(table *Table) ([]ProcedureInfo, error) {
+	var procs []ProcedureInfo
+	for table.AdvanceRow() {
+		name, _, err := table.GetStringByName("PROCEDURE_NAME")
+		if err != nil {
+			return nil, err
+		}
+		remarks, _, err := table.GetStringByName("REMARKS")
+		if err != nil {
+			return nil, err
+		}
+		var attrs struct {
+			ReadOnly           bool `json:"readOnly"`
+			SinglePartition    bool `json:"singlePartition"`
+			PartitionParameter *int `json:"partitionParameter"`
+		}
+		if remarks != "" {
+			if err := json.Unmarshal([]byte(remarks), &attrs); err != nil {
+				return nil, fmt.Errorf("Bad REMARKS of procedure %v: %v", name, err)
+			}
+		}
+		proc := ProcedureInfo{
+			Name:               name,
+			SinglePartition:    attrs.SinglePartition,
+			PartitionParameter: -1,
+			ReadOnly:           attrs.ReadOnly,
+		}
+		if attrs.SinglePartition && attrs.PartitionParameter != nil {
+			proc.PartitionParameter = *attrs.PartitionParameter
+		}
+		procs = append(procs, proc)
+	}
+	if err := table.Err(); err != nil {
+		return nil, err
+	}
+	return procs, nil
+}
+
+// callSysproc validates selector against known and calls procedure
+// with selector and then args.
+func (conn *Conn) callSysproc(procedure string, known map[string]bool,
+	selector string, args ...interface{}) (*Table, error) {
+	selector = strings.ToUpper(selector)
+	if !known[selector] {
+		return nil, fmt.Errorf("Unknown %v selector %v.", procedure, selector)
+	}
+	params := append([]interface{}{selector}, args...)
+	rsp, err := conn.CallIdempotent(procedure, params...)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Status() != SUCCESS {
+		return nil, fmt.Errorf("%v failed: %v %v", procedure, rsp.Status(), rsp.StatusString())
+	}
+	if len(rsp.tables) == 0 {
+		return nil, fmt.Errorf("%v returned no results.", procedure)
+	}
+	return rsp.Table(0), nil
+}
+
+// AdHoc runs sql, which need not be a stored procedure, by calling
+// @AdHoc. The response holds one table per statement.
+func (conn *Conn) AdHoc(sql string) (*Response, error) {
+	return conn.Call("@AdHoc", sql)
+}
+
+// AdHocArgs runs sql with its ? placeholders bound to args, so values
+// need not be spliced into the SQL text.
+func (conn *Conn) AdHocArgs(sql string, args ...interface{}) (*Response, error) {
+	if n := countPlaceholders(sql); n != len(args) {
+		return nil, fmt.Errorf("SQL has %d placeholders but %d arguments.", n, len(args))
+	}
+	params := append([]interface{}{sql}, args...)
+	return conn.Call("@AdHoc", params...)
+}
+
+// countPlaceholders counts the ? in sql outside quoted literals.
+func countPlaceholders(sql string) int {
+	n := 0
+	var quote rune
+	for _, c := range sql {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+		}
+	}
+	return n
+}
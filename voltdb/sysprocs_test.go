@@ -0,0 +1,222 @@
+package voltdb
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// invocation is a call received by recordingServer.
+type invocation struct {
+	proc   string
+	params []byte
+}
+
+// recordingServer sends each invocation it reads on calls and
+// answers it with a one row table.
+func recordingServer(t *testing.T, calls chan<- invocation) *testServer {
+	return newTestServer(t, func(c net.Conn) {
+		for {
+			proc, handle, params, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			calls <- invocation{proc, params.Bytes()}
+			writeTestResponse(c, handle, int8(SUCCESS), echoTable(proc))
+		}
+	})
+}
+
+func TestSysprocs(t *testing.T) {
+	calls := make(chan invocation, 1)
+	server := recordingServer(t, calls)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+
+	statistics := []byte{0x00, 0x02,
+		byte(vt_STRING), 0x00, 0x00, 0x00, 0x06, 'M', 'E', 'M', 'O', 'R', 'Y',
+		byte(vt_INT), 0x00, 0x00, 0x00, 0x01}
+	sysinfo := []byte{0x00, 0x01,
+		byte(vt_STRING), 0x00, 0x00, 0x00, 0x08, 'O', 'V', 'E', 'R', 'V', 'I', 'E', 'W'}
+	catalog := []byte{0x00, 0x01,
+		byte(vt_STRING), 0x00, 0x00, 0x00, 0x06, 'T', 'A', 'B', 'L', 'E', 'S'}
+	testVals := []struct {
+		call   func() (*Table, error)
+		proc   string
+		params []byte
+	}{
+		{func() (*Table, error) { return conn.Statistics("memory", true) }, "@Statistics", statistics},
+		{func() (*Table, error) { return conn.SystemInformation("OVERVIEW") }, "@SystemInformation", sysinfo},
+		{func() (*Table, error) { return conn.SystemCatalog("Tables") }, "@SystemCatalog", catalog},
+	}
+	for _, tv := range testVals {
+		table, err := tv.call()
+		if err != nil {
+			t.Fatalf("%v produced error %v", tv.proc, err)
+		}
+		call := <-calls
+		if call.proc != tv.proc || !bytes.Equal(call.params, tv.params) {
+			t.Errorf("%v sent %v %x wants %x", tv.proc, call.proc, call.params, tv.params)
+		}
+		if table.RowCount() != 1 {
+			t.Errorf("%v returned %v rows", tv.proc, table.RowCount())
+		}
+	}
+}
+
+func TestSysprocUnknownSelector(t *testing.T) {
+	var conn Conn
+	if _, err := conn.Statistics("BOGUS", false); err == nil {
+		t.Errorf("Expected error for unknown @Statistics selector")
+	}
+	if _, err := conn.SystemInformation("TABLES"); err == nil {
+		t.Errorf("Expected error for unknown @SystemInformation selector")
+	}
+}
+
+func TestAdHoc(t *testing.T) {
+	calls := make(chan invocation, 1)
+	server := newTestServer(t, func(c net.Conn) {
+		for {
+			proc, handle, params, err := readTestInvocation(c)
+			if err != nil {
+				return
+			}
+			calls <- invocation{proc, params.Bytes()}
+			var table bytes.Buffer
+			writeTestTable(&table, -128,
+				[]testColumn{{"ID", vt_INT}, {"NAME", vt_STRING}},
+				[][]interface{}{{int32(1), "one"}, {int32(2), "two"}})
+			writeTestResponse(c, handle, int8(SUCCESS), table.Bytes())
+		}
+	})
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+
+	sql := "SELECT ID, NAME FROM T ORDER BY ID;"
+	rsp, err := conn.AdHoc(sql)
+	if err != nil {
+		t.Fatalf("AdHoc produced error %v", err)
+	}
+	var expected bytes.Buffer
+	writeShort(&expected, 1)
+	writeByte(&expected, vt_STRING)
+	writeString(&expected, sql)
+	if call := <-calls; call.proc != "@AdHoc" || !bytes.Equal(call.params, expected.Bytes()) {
+		t.Errorf("AdHoc sent %v %x wants %x", call.proc, call.params, expected.Bytes())
+	}
+
+	table := rsp.Table(0)
+	var names []string
+	for table.AdvanceRow() {
+		var id int32
+		var name string
+		if err := table.Scan(&id, &name); err != nil {
+			t.Fatalf("Scan produced error %v", err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 2 || names[0] != "one" || names[1] != "two" {
+		t.Errorf("Bad AdHoc result %v", names)
+	}
+}
+
+func TestCountPlaceholders(t *testing.T) {
+	testVals := map[string]int{
+		"SELECT * FROM T":                           0,
+		"SELECT * FROM T WHERE A = ? AND B = ?":     2,
+		"SELECT * FROM T WHERE A = '?' AND B = ?":   1,
+		`SELECT "?" FROM T WHERE A = 'it''s?' OR ?`: 1,
+	}
+	for sql, expected := range testVals {
+		if n := countPlaceholders(sql); n != expected {
+			t.Errorf("countPlaceholders(%v) has %v wants %v", sql, n, expected)
+		}
+	}
+}
+
+func TestAdHocArgs(t *testing.T) {
+	calls := make(chan invocation, 1)
+	server := recordingServer(t, calls)
+	defer server.close()
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+
+	sql := "SELECT * FROM T WHERE ID = ? AND NAME = ?;"
+	if _, err := conn.AdHocArgs(sql, int32(5), "five"); err != nil {
+		t.Fatalf("AdHocArgs produced error %v", err)
+	}
+	var expected bytes.Buffer
+	writeParameterSet(&expected, []interface{}{sql, int32(5), "five"})
+	if call := <-calls; call.proc != "@AdHoc" || !bytes.Equal(call.params, expected.Bytes()) {
+		t.Errorf("AdHocArgs sent %v %x wants %x", call.proc, call.params, expected.Bytes())
+	}
+
+	if _, err := conn.AdHocArgs(sql, int32(5)); err == nil {
+		t.Errorf("Expected error for missing argument")
+	}
+}
+
+func TestProcedures(t *testing.T) {
+	server, err := NewMockServer()
+	if err != nil {
+		t.Fatalf("NewMockServer produced error %v", err)
+	}
+	defer server.Close()
+	columns := []string{"PROCEDURE_CAT", "PROCEDURE_SCHEM", "PROCEDURE_NAME",
+		"RESERVED1", "RESERVED2", "RESERVED3", "REMARKS", "PROCEDURE_TYPE", "SPECIFIC_NAME"}
+	rows := [][]interface{}{
+		{nil, nil, "GetUser", nil, nil, nil,
+			`{"partitionParameterType":6,"partitionParameter":1,"singlePartition":true,"readOnly":true}`,
+			int16(1), "GetUser"},
+		{nil, nil, "Rebalance", nil, nil, nil,
+			`{"singlePartition":false,"readOnly":false}`, int16(1), "Rebalance"},
+	}
+	server.Handle("@SystemCatalog", func(params []interface{}) *MockResponse {
+		if len(params) != 1 || params[0] != "PROCEDURES" {
+			return &MockResponse{Status: GRACEFUL_FAILURE, StatusString: "Bad selector"}
+		}
+		return &MockResponse{Tables: []*MockTable{{Columns: columns, Rows: rows}}}
+	})
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	procs, err := conn.Procedures()
+	if err != nil {
+		t.Fatalf("Procedures produced error %v", err)
+	}
+	expected := []ProcedureInfo{
+		{Name: "GetUser", SinglePartition: true, PartitionParameter: 1, ReadOnly: true},
+		{Name: "Rebalance", PartitionParameter: -1},
+	}
+	if !reflect.DeepEqual(procs, expected) {
+		t.Errorf("Bad procedures have %+v wants %+v", procs, expected)
+	}
+}
+
+func TestProceduresBadRemarks(t *testing.T) {
+	table := newTestTable(t,
+		[]testColumn{{"PROCEDURE_NAME", vt_STRING}, {"REMARKS", vt_STRING}},
+		[][]interface{}{{"P", "{"}})
+	if _, err := parseProcedures(table); err == nil {
+		t.Errorf("Expected error for malformed REMARKS")
+	}
+}
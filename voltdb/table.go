@@ -0,0 +1,568 @@
+package voltdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// table.go provides typed, by-column access to the rows of a Table.
+// AdvanceRow moves to the next row; the Get methods then decode a
+// single column of that row. The bool returned by each Get method
+// is true when the column is SQL NULL.
+
+// AdvanceRow moves to the next row of the table. It returns false
+// when there are no more rows or the row data is malformed; see Err.
+func (table *Table) AdvanceRow() bool {
+	table.row = nil
+	table.colOffsets = table.colOffsets[:0]
+	r := &table.rows
+	if table.err != nil || r.Len() == 0 {
+		return false
+	}
+	rowLength, err := readInt(r)
+	if err != nil {
+		table.err = truncated(err, "table")
+		return false
+	}
+	if rowLength < 0 || int(rowLength) > r.Len() {
+		table.err = protocolError(ErrLengthMismatch, "Bad row length %d.", rowLength)
+		return false
+	}
+	row := r.Next(int(rowLength))
+	offsets, err := columnOffsets(table.columnTypes, row)
+	if err != nil {
+		table.err = err
+		return false
+	}
+	table.row = row
+	table.colOffsets = offsets
+	return true
+}
+
+// columnOffsets returns the starting offset of each column in row.
+func columnOffsets(columnTypes []int8, row []byte) ([]int, error) {
+	offsets := make([]int, len(columnTypes))
+	offset := 0
+	for idx, vt := range columnTypes {
+		offsets[idx] = offset
+		size, err := columnSize(vt, row[offset:])
+		if err != nil {
+			return nil, err
+		}
+		offset += size
+		if offset > len(row) {
+			return nil, protocolError(ErrTruncatedMessage, "Row data too short for column %d.", idx)
+		}
+	}
+	return offsets, nil
+}
+
+// columnSize returns the serialized size of the value of type vt at
+// the start of data.
+func columnSize(vt int8, data []byte) (int, error) {
+	switch vt {
+	case vt_BOOL:
+		return 1, nil
+	case vt_SHORT:
+		return 2, nil
+	case vt_INT:
+		return 4, nil
+	case vt_LONG, vt_FLOAT, vt_TIMESTAMP:
+		return 8, nil
+	case vt_DECIMAL, vt_POINT:
+		return 16, nil
+	case vt_STRING, vt_VARBIN, vt_GEOGRAPHY:
+		length, err := readInt(bytes.NewReader(data))
+		if err != nil {
+			return 0, err
+		}
+		if length == -1 {
+			return 4, nil
+		}
+		if length < -1 {
+			return 0, protocolError(ErrLengthMismatch, "Invalid value length %d.", length)
+		}
+		if 4+int(length) > len(data) {
+			return 0, protocolError(ErrTruncatedMessage, "Value length %d exceeds the row.", length)
+		}
+		return 4 + int(length), nil
+	}
+	return 0, protocolError(ErrUnexpectedType, "Unknown column type %d.", vt)
+}
+
+// column returns a reader positioned at column colIndex of the
+// current row after checking that the column has type vt.
+func (table *Table) column(colIndex int, vt int8) (io.Reader, error) {
+	if err := table.checkColumn(colIndex, vt); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(table.row[table.colOffsets[colIndex]:]), nil
+}
+
+// checkColumn checks that there is a current row and that column
+// colIndex has type vt.
+func (table *Table) checkColumn(colIndex int, vt int8) error {
+	if table.row == nil {
+		return fmt.Errorf("No current row. Call AdvanceRow first.")
+	}
+	if colIndex < 0 || colIndex >= len(table.columnTypes) {
+		return fmt.Errorf("Column index %d out of range.", colIndex)
+	}
+	if table.columnTypes[colIndex] != vt {
+		return protocolError(ErrUnexpectedType, "Column %d has type %d not %d.",
+			colIndex, table.columnTypes[colIndex], vt)
+	}
+	return nil
+}
+
+// stringBytes returns the bytes of the STRING column colIndex of the
+// current row without copying them.
+func (table *Table) stringBytes(colIndex int) ([]byte, bool, error) {
+	if err := table.checkColumn(colIndex, vt_STRING); err != nil {
+		return nil, false, err
+	}
+	data := table.row[table.colOffsets[colIndex]:]
+	length := int32(order.Uint32(data))
+	if length == -1 {
+		return nil, true, nil
+	}
+	bs := data[4 : 4+length]
+	if table.validUTF8 && !utf8.Valid(bs) {
+		return nil, false, fmt.Errorf("Column %d is not valid UTF-8.", colIndex)
+	}
+	return bs, false, nil
+}
+
+// SetValidateUTF8 sets whether STRING values are checked to be valid
+// UTF-8. When set, reading an invalid value returns an error. By
+// default values are returned unchecked.
+func (table *Table) SetValidateUTF8(validate bool) {
+	table.validUTF8 = validate
+}
+
+// GetByte returns the TINYINT value of column colIndex.
+func (table *Table) GetByte(colIndex int) (int8, bool, error) {
+	r, err := table.column(colIndex, vt_TINYINT)
+	if err != nil {
+		return 0, false, err
+	}
+	return readNullableByte(r)
+}
+
+// GetShort returns the SMALLINT value of column colIndex.
+func (table *Table) GetShort(colIndex int) (int16, bool, error) {
+	r, err := table.column(colIndex, vt_SHORT)
+	if err != nil {
+		return 0, false, err
+	}
+	return readNullableShort(r)
+}
+
+// GetInt returns the INTEGER value of column colIndex.
+func (table *Table) GetInt(colIndex int) (int32, bool, error) {
+	r, err := table.column(colIndex, vt_INT)
+	if err != nil {
+		return 0, false, err
+	}
+	return readNullableInt(r)
+}
+
+// GetLong returns the BIGINT value of column colIndex.
+func (table *Table) GetLong(colIndex int) (int64, bool, error) {
+	r, err := table.column(colIndex, vt_LONG)
+	if err != nil {
+		return 0, false, err
+	}
+	return readNullableLong(r)
+}
+
+// GetFloat returns the FLOAT value of column colIndex.
+func (table *Table) GetFloat(colIndex int) (float64, bool, error) {
+	r, err := table.column(colIndex, vt_FLOAT)
+	if err != nil {
+		return 0, false, err
+	}
+	return readNullableFloat(r)
+}
+
+// GetString returns the STRING value of column colIndex.
+func (table *Table) GetString(colIndex int) (string, bool, error) {
+	bs, isNull, err := table.stringBytes(colIndex)
+	return string(bs), isNull, err
+}
+
+// GetStringBytes returns the STRING value of column colIndex as UTF-8
+// bytes. It does not copy: the slice shares the table's memory and
+// must not be modified.
+func (table *Table) GetStringBytes(colIndex int) ([]byte, bool, error) {
+	return table.stringBytes(colIndex)
+}
+
+// GetTimestamp returns the TIMESTAMP value of column colIndex in UTC.
+// NULL, sent as math.MinInt64 microseconds, is reported by the bool
+// with the zero time.Time, so it is never confused with the Unix
+// epoch, which is a timestamp of zero.
+func (table *Table) GetTimestamp(colIndex int) (time.Time, bool, error) {
+	r, err := table.column(colIndex, vt_TIMESTAMP)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return readNullableTimestamp(r)
+}
+
+// GetDecimal returns the DECIMAL value of column colIndex.
+func (table *Table) GetDecimal(colIndex int) (*big.Rat, bool, error) {
+	r, err := table.column(colIndex, vt_DECIMAL)
+	if err != nil {
+		return nil, false, err
+	}
+	d, err := readDecimal(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return d, d == nil, nil
+}
+
+// GetPoint returns the GEOGRAPHY_POINT value of column colIndex.
+func (table *Table) GetPoint(colIndex int) (GeographyPoint, bool, error) {
+	r, err := table.column(colIndex, vt_POINT)
+	if err != nil {
+		return GeographyPoint{}, false, err
+	}
+	return readGeographyPoint(r)
+}
+
+// GetGeography returns the GEOGRAPHY value of column colIndex.
+func (table *Table) GetGeography(colIndex int) (*Geography, bool, error) {
+	r, err := table.column(colIndex, vt_GEOGRAPHY)
+	if err != nil {
+		return nil, false, err
+	}
+	g, err := readGeography(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return g, g == nil, nil
+}
+
+// ColumnIndex returns the index of the column named name. Names are
+// matched case-insensitively, as VoltDB does.
+func (table *Table) ColumnIndex(name string) (int, error) {
+	if idx := table.lookupColumn(name); idx >= 0 {
+		return idx, nil
+	}
+	return -1, fmt.Errorf("No column named %v.", name)
+}
+
+// lookupColumn is ColumnIndex without the error.
+func (table *Table) lookupColumn(name string) int {
+	for idx, cn := range table.columnNames {
+		if strings.EqualFold(cn, name) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// GetIntByName returns the INTEGER value of the column named name.
+func (table *Table) GetIntByName(name string) (int32, bool, error) {
+	colIndex, err := table.ColumnIndex(name)
+	if err != nil {
+		return 0, false, err
+	}
+	return table.GetInt(colIndex)
+}
+
+// GetLongByName returns the BIGINT value of the column named name.
+func (table *Table) GetLongByName(name string) (int64, bool, error) {
+	colIndex, err := table.ColumnIndex(name)
+	if err != nil {
+		return 0, false, err
+	}
+	return table.GetLong(colIndex)
+}
+
+// GetFloatByName returns the FLOAT value of the column named name.
+func (table *Table) GetFloatByName(name string) (float64, bool, error) {
+	colIndex, err := table.ColumnIndex(name)
+	if err != nil {
+		return 0, false, err
+	}
+	return table.GetFloat(colIndex)
+}
+
+// GetStringByName returns the STRING value of the column named name.
+func (table *Table) GetStringByName(name string) (string, bool, error) {
+	colIndex, err := table.ColumnIndex(name)
+	if err != nil {
+		return "", false, err
+	}
+	return table.GetString(colIndex)
+}
+
+// GetTimestampByName returns the TIMESTAMP value of the column named
+// name in UTC.
+func (table *Table) GetTimestampByName(name string) (time.Time, bool, error) {
+	colIndex, err := table.ColumnIndex(name)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return table.GetTimestamp(colIndex)
+}
+
+// GetDecimalByName returns the DECIMAL value of the column named name.
+func (table *Table) GetDecimalByName(name string) (*big.Rat, bool, error) {
+	colIndex, err := table.ColumnIndex(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return table.GetDecimal(colIndex)
+}
+
+// value returns column colIndex of the current row as its natural Go
+// type, or nil if it is SQL NULL.
+func (table *Table) value(colIndex int) (interface{}, error) {
+	if colIndex < 0 || colIndex >= len(table.columnTypes) {
+		return nil, fmt.Errorf("Column index %d out of range.", colIndex)
+	}
+	vt := table.columnTypes[colIndex]
+	r, err := table.column(colIndex, vt)
+	if err != nil {
+		return nil, err
+	}
+	var val interface{}
+	var isNull bool
+	switch vt {
+	case vt_BOOL:
+		val, isNull, err = readNullableByte(r)
+	case vt_SHORT:
+		val, isNull, err = readNullableShort(r)
+	case vt_INT:
+		val, isNull, err = readNullableInt(r)
+	case vt_LONG:
+		val, isNull, err = readNullableLong(r)
+	case vt_FLOAT:
+		val, isNull, err = readNullableFloat(r)
+	case vt_STRING:
+		var bs []byte
+		bs, isNull, err = table.stringBytes(colIndex)
+		val = string(bs)
+	case vt_TIMESTAMP:
+		val, isNull, err = readNullableTimestamp(r)
+	case vt_DECIMAL:
+		var d *big.Rat
+		d, err = readDecimal(r)
+		val, isNull = d, d == nil
+	case vt_VARBIN:
+		var bs []byte
+		bs, err = readVarbinary(r)
+		if err == nil && bs != nil && table.compression == Gzip {
+			bs, err = decompress(bs)
+		}
+		val, isNull = bs, bs == nil
+	case vt_POINT:
+		val, isNull, err = readGeographyPoint(r)
+	case vt_GEOGRAPHY:
+		var g *Geography
+		g, err = readGeography(r)
+		val, isNull = g, g == nil
+	default:
+		return nil, protocolError(ErrUnexpectedType, "Unknown column type %d.", vt)
+	}
+	if err != nil || isNull {
+		return nil, err
+	}
+	return val, nil
+}
+
+// Scan copies the columns of the current row into dest, which must
+// hold one pointer per column. Supported pointer types are *int8
+// (TINYINT), *int16 (SMALLINT), *int32 (INTEGER), *int64 (BIGINT),
+// *float64 (FLOAT), *string (STRING), *time.Time (TIMESTAMP), *[]byte
+// (VARBINARY), **big.Rat (DECIMAL), *GeographyPoint (GEOGRAPHY_POINT)
+// and **Geography (GEOGRAPHY), as well as the Nullable types.
+// A NULL column sets its destination to the zero value.
+func (table *Table) Scan(dest ...interface{}) error {
+	if table.row == nil {
+		return fmt.Errorf("No current row. Call AdvanceRow first.")
+	}
+	if len(dest) != len(table.columnTypes) {
+		return fmt.Errorf("Scan expects %d destinations, not %d.",
+			len(table.columnTypes), len(dest))
+	}
+	for idx, d := range dest {
+		if err := table.scanColumn(idx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanColumn stores column colIndex of the current row in dest.
+func (table *Table) scanColumn(colIndex int, dest interface{}) error {
+	var vt int8
+	switch dest.(type) {
+	case *int8:
+		vt = vt_TINYINT
+	case *int16:
+		vt = vt_SHORT
+	case *int32:
+		vt = vt_INT
+	case *int64:
+		vt = vt_LONG
+	case *float64:
+		vt = vt_FLOAT
+	case *string:
+		vt = vt_STRING
+	case *time.Time:
+		vt = vt_TIMESTAMP
+	case *[]byte:
+		vt = vt_VARBIN
+	case **big.Rat:
+		vt = vt_DECIMAL
+	case *GeographyPoint:
+		vt = vt_POINT
+	case **Geography:
+		vt = vt_GEOGRAPHY
+	case *NullableInt32:
+		vt = vt_INT
+	case *NullableInt64:
+		vt = vt_LONG
+	case *NullableFloat64:
+		vt = vt_FLOAT
+	case *NullableString:
+		vt = vt_STRING
+	case *NullableTime:
+		vt = vt_TIMESTAMP
+	default:
+		return fmt.Errorf("Unsupported Scan destination %T for column %d.", dest, colIndex)
+	}
+	if table.columnTypes[colIndex] != vt {
+		return protocolError(ErrUnexpectedType, "Can not scan column %d of type %d into %T.",
+			colIndex, table.columnTypes[colIndex], dest)
+	}
+	val, err := table.value(colIndex)
+	if err != nil {
+		return err
+	}
+	switch d := dest.(type) {
+	case *int8:
+		*d, _ = val.(int8)
+	case *int16:
+		*d, _ = val.(int16)
+	case *int32:
+		*d, _ = val.(int32)
+	case *int64:
+		*d, _ = val.(int64)
+	case *float64:
+		*d, _ = val.(float64)
+	case *string:
+		*d, _ = val.(string)
+	case *time.Time:
+		*d, _ = val.(time.Time)
+	case *[]byte:
+		*d, _ = val.([]byte)
+	case **big.Rat:
+		*d, _ = val.(*big.Rat)
+	case *GeographyPoint:
+		*d, _ = val.(GeographyPoint)
+	case **Geography:
+		*d, _ = val.(*Geography)
+	case *NullableInt32:
+		d.Value, d.Valid = val.(int32)
+	case *NullableInt64:
+		d.Value, d.Valid = val.(int64)
+	case *NullableFloat64:
+		d.Value, d.Valid = val.(float64)
+	case *NullableString:
+		d.Value, d.Valid = val.(string)
+	case *NullableTime:
+		d.Value, d.Valid = val.(time.Time)
+	}
+	return nil
+}
+
+// Err returns the error that stopped AdvanceRow, if any. AdvanceRow
+// returns false both at the end of the table and at a malformed row,
+// so Err tells the two apart.
+func (table *Table) Err() error {
+	return table.err
+}
+
+// unreadRows returns a Table holding the rows of table not yet read
+// by AdvanceRow, so they can be read without advancing table.
+func (table *Table) unreadRows() *Table {
+	return &Table{
+		columnCount: table.columnCount,
+		columnTypes: table.columnTypes,
+		columnNames: table.columnNames,
+		rows:        *bytes.NewBuffer(table.rows.Bytes()),
+		rowData:     table.rowData,
+		validUTF8:   table.validUTF8,
+		compression: table.compression,
+		err:         table.err,
+	}
+}
+
+// allRows returns a Table holding every row of table, including those
+// already read by AdvanceRow.
+func (table *Table) allRows() *Table {
+	rows := table.unreadRows()
+	rows.rows = *bytes.NewBuffer(table.rowData)
+	rows.err = nil
+	return rows
+}
+
+// SumColumn returns the sum of integer column colIndex over every row
+// of the table, skipping NULLs. It totals, for example, the rows
+// modified by each partition of a multi-partition write. A total is
+// a property of the whole table, so unlike Rows, ToMaps, WriteCSV and
+// MarshalJSON, which return only the rows not yet read, SumColumn
+// counts rows already read by AdvanceRow; it does not move the row
+// cursor either. Columns that are not TINYINT, SMALLINT, INTEGER or
+// BIGINT, and sums that overflow a BIGINT, are errors.
+func (table *Table) SumColumn(colIndex int) (int64, error) {
+	if colIndex < 0 || colIndex >= len(table.columnTypes) {
+		return 0, fmt.Errorf("Column index %d out of range.", colIndex)
+	}
+	switch vt := table.columnTypes[colIndex]; vt {
+	case vt_TINYINT, vt_SHORT, vt_INT, vt_LONG:
+	default:
+		return 0, protocolError(ErrUnexpectedType, "Column %d has type %d, not an integer type.", colIndex, vt)
+	}
+	var sum int64
+	rows := table.allRows()
+	for rows.AdvanceRow() {
+		val, err := rows.value(colIndex)
+		if err != nil {
+			return 0, err
+		}
+		var n int64
+		switch x := val.(type) {
+		case nil:
+			continue
+		case int8:
+			n = int64(x)
+		case int16:
+			n = int64(x)
+		case int32:
+			n = int64(x)
+		case int64:
+			n = x
+		}
+		if (n > 0 && sum > math.MaxInt64-n) || (n < 0 && sum < math.MinInt64-n) {
+			return 0, fmt.Errorf("Sum of column %d overflows a BIGINT.", colIndex)
+		}
+		sum += n
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
@@ -0,0 +1,498 @@
+package voltdb
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testColumn names and types a column of a table built by
+// writeTestTable.
+type testColumn struct {
+	name string
+	vt   int8
+}
+
+// writeTestTable serializes a table in the vt_TABLE layout. nil row
+// values are written as the column type's NULL.
+func writeTestTable(w *bytes.Buffer, status int8, cols []testColumn, rows [][]interface{}) {
+	names := make([]string, len(cols))
+	types := make([]int8, len(cols))
+	for idx, col := range cols {
+		names[idx], types[idx] = col.name, col.vt
+	}
+	if err := writeTable(w, status, names, types, rows); err != nil {
+		panic(err)
+	}
+}
+
+// newTestTable returns the decoded form of a table built by
+// writeTestTable.
+func newTestTable(t *testing.T, cols []testColumn, rows [][]interface{}) *Table {
+	var b bytes.Buffer
+	writeTestTable(&b, -128, cols, rows)
+	table, err := deserializeTable(&b)
+	if err != nil {
+		t.Fatalf("deserializeTable produced error %v", err)
+	}
+	return &table
+}
+
+// a two column, two row table: (1, "a"), (NULL, NULL).
+var capturedTable = []byte{
+	0x00, 0x00, 0x00, 0x34, // total length
+	0x00, 0x00, 0x00, 0x13, // metadata length
+	0x80,       // status
+	0x00, 0x02, // column count
+	0x05, 0x09, // INTEGER, STRING
+	0x00, 0x00, 0x00, 0x02, 'I', 'D',
+	0x00, 0x00, 0x00, 0x04, 'N', 'A', 'M', 'E',
+	0x00, 0x00, 0x00, 0x02, // row count
+	0x00, 0x00, 0x00, 0x09,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 'a',
+	0x00, 0x00, 0x00, 0x08,
+	0x80, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF,
+}
+
+func TestCapturedTable(t *testing.T) {
+	table, err := deserializeTable(bytes.NewBuffer(capturedTable))
+	if err != nil {
+		t.Fatalf("deserializeTable produced error %v", err)
+	}
+	if !table.AdvanceRow() {
+		t.Fatalf("Expected first row")
+	}
+	if v, isNull, err := table.GetInt(0); v != 1 || isNull || err != nil {
+		t.Errorf("Bad GetInt(0) %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetString(1); v != "a" || isNull || err != nil {
+		t.Errorf("Bad GetString(1) %v, %v, %v", v, isNull, err)
+	}
+	if !table.AdvanceRow() {
+		t.Fatalf("Expected second row")
+	}
+	if _, isNull, err := table.GetInt(0); !isNull || err != nil {
+		t.Errorf("Expected NULL GetInt(0) %v, %v", isNull, err)
+	}
+	if _, isNull, err := table.GetString(1); !isNull || err != nil {
+		t.Errorf("Expected NULL GetString(1) %v, %v", isNull, err)
+	}
+	if table.AdvanceRow() {
+		t.Errorf("Expected no third row")
+	}
+}
+
+func TestTableStatusCode(t *testing.T) {
+	if table := newTestTable(t, nil, nil); table.StatusCode() != UNINITIALIZED_TABLE_STATUS_CODE {
+		t.Errorf("Bad StatusCode() have %v wants %v", table.StatusCode(), UNINITIALIZED_TABLE_STATUS_CODE)
+	}
+	var b bytes.Buffer
+	writeTestTable(&b, 5, []testColumn{{"ID", vt_INT}}, [][]interface{}{{int32(1)}})
+	table, err := deserializeTable(&b)
+	if err != nil {
+		t.Fatalf("deserializeTable produced error %v", err)
+	}
+	if table.StatusCode() != 5 {
+		t.Errorf("Bad StatusCode() have %v wants 5", table.StatusCode())
+	}
+	if !table.AdvanceRow() {
+		t.Errorf("Expected the status byte not to disturb the rows")
+	}
+}
+
+func TestCapturedTableColumns(t *testing.T) {
+	table, err := deserializeTable(bytes.NewBuffer(capturedTable))
+	if err != nil {
+		t.Fatalf("deserializeTable produced error %v", err)
+	}
+	if table.ColumnCount() != 2 {
+		t.Fatalf("Bad ColumnCount() have %v wants 2", table.ColumnCount())
+	}
+	types := []int8{vt_INT, vt_STRING}
+	names := []string{"ID", "NAME"}
+	for i := range types {
+		if table.ColumnType(i) != types[i] {
+			t.Errorf("Bad ColumnType(%d) have %v wants %v", i, table.ColumnType(i), types[i])
+		}
+		if table.ColumnName(i) != names[i] {
+			t.Errorf("Bad ColumnName(%d) have %v wants %v", i, table.ColumnName(i), names[i])
+		}
+	}
+}
+
+func TestTypedAccessors(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 123456000, time.UTC)
+	amount, _ := new(big.Rat).SetString("-1234.5678")
+	cols := []testColumn{{"I", vt_INT}, {"L", vt_LONG}, {"F", vt_FLOAT},
+		{"S", vt_STRING}, {"T", vt_TIMESTAMP}, {"D", vt_DECIMAL}}
+	table := newTestTable(t, cols, [][]interface{}{
+		{int32(7), int64(1) << 40, 2.5, "seven", ts, amount},
+		{nil, nil, nil, nil, nil, nil},
+	})
+
+	if !table.AdvanceRow() {
+		t.Fatalf("Expected first row")
+	}
+	if v, isNull, err := table.GetInt(0); v != 7 || isNull || err != nil {
+		t.Errorf("Bad GetInt %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetLong(1); v != 1<<40 || isNull || err != nil {
+		t.Errorf("Bad GetLong %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetFloat(2); v != 2.5 || isNull || err != nil {
+		t.Errorf("Bad GetFloat %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetString(3); v != "seven" || isNull || err != nil {
+		t.Errorf("Bad GetString %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetTimestamp(4); !v.Equal(ts) || isNull || err != nil {
+		t.Errorf("Bad GetTimestamp %v, %v, %v", v, isNull, err)
+	}
+	if v, isNull, err := table.GetDecimal(5); v == nil || v.Cmp(amount) != 0 || isNull || err != nil {
+		t.Errorf("Bad GetDecimal %v, %v, %v", v, isNull, err)
+	}
+
+	if !table.AdvanceRow() {
+		t.Fatalf("Expected second row")
+	}
+	for idx := range cols {
+		var isNull bool
+		var err error
+		switch idx {
+		case 0:
+			_, isNull, err = table.GetInt(idx)
+		case 1:
+			_, isNull, err = table.GetLong(idx)
+		case 2:
+			_, isNull, err = table.GetFloat(idx)
+		case 3:
+			_, isNull, err = table.GetString(idx)
+		case 4:
+			_, isNull, err = table.GetTimestamp(idx)
+		case 5:
+			_, isNull, err = table.GetDecimal(idx)
+		}
+		if !isNull || err != nil {
+			t.Errorf("Expected NULL in column %d, have %v, %v", idx, isNull, err)
+		}
+	}
+}
+
+func TestTypedAccessorErrors(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"I", vt_INT}},
+		[][]interface{}{{int32(1)}})
+	if _, _, err := table.GetInt(0); err == nil {
+		t.Errorf("Expected error reading before AdvanceRow")
+	}
+	table.AdvanceRow()
+	if _, _, err := table.GetString(0); err == nil {
+		t.Errorf("Expected error reading INTEGER column as string")
+	}
+	if _, _, err := table.GetInt(1); err == nil {
+		t.Errorf("Expected error reading out of range column")
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"ID", vt_INT}, {"Name", vt_STRING}},
+		[][]interface{}{{int32(3), "three"}})
+	testVals := map[string]int{"ID": 0, "id": 0, "Name": 1, "NAME": 1, "name": 1}
+	for name, expected := range testVals {
+		if idx, err := table.ColumnIndex(name); idx != expected || err != nil {
+			t.Errorf("ColumnIndex(%v) has %v, %v wants %v", name, idx, err, expected)
+		}
+	}
+	if _, err := table.ColumnIndex("missing"); err == nil {
+		t.Errorf("Expected error for absent column")
+	}
+
+	table.AdvanceRow()
+	if v, _, err := table.GetIntByName("Id"); v != 3 || err != nil {
+		t.Errorf("Bad GetIntByName %v, %v", v, err)
+	}
+	if v, _, err := table.GetStringByName("name"); v != "three" || err != nil {
+		t.Errorf("Bad GetStringByName %v, %v", v, err)
+	}
+	if _, _, err := table.GetIntByName("missing"); err == nil {
+		t.Errorf("Expected error for absent column")
+	}
+}
+
+func TestScan(t *testing.T) {
+	ts := time.Date(2012, 5, 17, 10, 30, 15, 0, time.UTC)
+	cols := []testColumn{{"I", vt_INT}, {"L", vt_LONG}, {"F", vt_FLOAT},
+		{"S", vt_STRING}, {"T", vt_TIMESTAMP}, {"B", vt_VARBIN}}
+	table := newTestTable(t, cols, [][]interface{}{
+		{int32(7), int64(8), 2.5, "seven", ts, []byte{1, 2}},
+		{nil, nil, nil, nil, nil, nil},
+	})
+
+	var i int32
+	var l int64
+	var f float64
+	var s string
+	var ts2 time.Time
+	var b []byte
+	table.AdvanceRow()
+	if err := table.Scan(&i, &l, &f, &s, &ts2, &b); err != nil {
+		t.Fatalf("Scan produced error %v", err)
+	}
+	if i != 7 || l != 8 || f != 2.5 || s != "seven" || !ts2.Equal(ts) || !bytes.Equal(b, []byte{1, 2}) {
+		t.Errorf("Bad Scan %v, %v, %v, %v, %v, %v", i, l, f, s, ts2, b)
+	}
+
+	table.AdvanceRow()
+	if err := table.Scan(&i, &l, &f, &s, &ts2, &b); err != nil {
+		t.Fatalf("Scan produced error %v", err)
+	}
+	if i != 0 || l != 0 || f != 0 || s != "" || !ts2.IsZero() || b != nil {
+		t.Errorf("Expected zero values for NULLs, have %v, %v, %v, %v, %v, %v",
+			i, l, f, s, ts2, b)
+	}
+}
+
+// a one row DECIMAL table holding 10^-12, the smallest DECIMAL step.
+var capturedDecimalTable = []byte{
+	0x00, 0x00, 0x00, 0x25, // total length
+	0x00, 0x00, 0x00, 0x09, // metadata length
+	0x80,       // status
+	0x00, 0x01, // column count
+	0x16,                        // DECIMAL
+	0x00, 0x00, 0x00, 0x01, 'D', // column name
+	0x00, 0x00, 0x00, 0x01, // row count
+	0x00, 0x00, 0x00, 0x10,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+}
+
+func TestGetDecimal(t *testing.T) {
+	table, err := deserializeTable(bytes.NewReader(capturedDecimalTable))
+	if err != nil {
+		t.Fatalf("deserializeTable produced error %v", err)
+	}
+	table.AdvanceRow()
+	if d, isNull, err := table.GetDecimal(0); isNull || err != nil || d.Cmp(big.NewRat(1, 1000000000000)) != 0 {
+		t.Errorf("Bad GetDecimal %v, %v, %v wants 0.000000000001", d, isNull, err)
+	}
+
+	positive, _ := new(big.Rat).SetString("12345678901234567890.123456789012")
+	negative, _ := new(big.Rat).SetString("-0.5")
+	decimals := newTestTable(t, []testColumn{{"D", vt_DECIMAL}},
+		[][]interface{}{{positive}, {negative}, {nil}})
+	for _, expected := range []*big.Rat{positive, negative, nil} {
+		decimals.AdvanceRow()
+		d, isNull, err := decimals.GetDecimal(0)
+		if err != nil || isNull != (expected == nil) || (expected != nil && d.Cmp(expected) != 0) {
+			t.Errorf("Bad GetDecimal %v, %v, %v wants %v", d, isNull, err, expected)
+		}
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"I", vt_INT}, {"S", vt_STRING}},
+		[][]interface{}{{int32(1), "one"}})
+	var i int32
+	var s string
+	if err := table.Scan(&i, &s); err == nil {
+		t.Errorf("Expected error scanning before AdvanceRow")
+	}
+	table.AdvanceRow()
+	if err := table.Scan(&i); err == nil {
+		t.Errorf("Expected error for too few destinations")
+	}
+	if err := table.Scan(&s, &i); err == nil {
+		t.Errorf("Expected error for mismatched types")
+	}
+	var u uint
+	if err := table.Scan(&i, &u); err == nil {
+		t.Errorf("Expected error for unsupported destination")
+	}
+}
+
+func TestGetStringBytes(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"S", vt_STRING}},
+		[][]interface{}{{"seven"}, {nil}})
+	table.AdvanceRow()
+	if v, isNull, err := table.GetStringBytes(0); string(v) != "seven" || isNull || err != nil {
+		t.Errorf("Bad GetStringBytes %q, %v, %v", v, isNull, err)
+	}
+	table.AdvanceRow()
+	if v, isNull, err := table.GetStringBytes(0); v != nil || !isNull || err != nil {
+		t.Errorf("Bad NULL GetStringBytes %q, %v, %v", v, isNull, err)
+	}
+}
+
+// benchStringTable has 100 rows of 4KB strings.
+func benchStringTable(b *testing.B) *bytes.Buffer {
+	val := string(bytes.Repeat([]byte("v"), 4096))
+	rows := make([][]interface{}, 100)
+	for idx := range rows {
+		rows[idx] = []interface{}{val}
+	}
+	var buf bytes.Buffer
+	writeTestTable(&buf, -128, []testColumn{{"S", vt_STRING}}, rows)
+	return &buf
+}
+
+func benchmarkStrings(b *testing.B, get func(table *Table)) {
+	data := benchStringTable(b).Bytes()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		table, err := deserializeTable(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for table.AdvanceRow() {
+			get(&table)
+		}
+	}
+}
+
+// reads through an io.Reader, copying each cell twice.
+func BenchmarkReadStringCopy(b *testing.B) {
+	benchmarkStrings(b, func(table *Table) {
+		r, _ := table.column(0, vt_STRING)
+		readNullableString(r)
+	})
+}
+
+func BenchmarkGetString(b *testing.B) {
+	benchmarkStrings(b, func(table *Table) {
+		table.GetString(0)
+	})
+}
+
+func BenchmarkGetStringBytes(b *testing.B) {
+	benchmarkStrings(b, func(table *Table) {
+		table.GetStringBytes(0)
+	})
+}
+
+func TestValidateUTF8(t *testing.T) {
+	valid := "⋒♈ℱ8 ᔕ♈ᖇᓰﬡᘐ"
+	invalid := string([]byte{'a', 0xff, 0xfe, 'b'})
+	table := newTestTable(t, []testColumn{{"S", vt_STRING}},
+		[][]interface{}{{valid}, {invalid}})
+
+	table.SetValidateUTF8(true)
+	table.AdvanceRow()
+	if v, _, err := table.GetString(0); v != valid || err != nil {
+		t.Errorf("Bad GetString %v, %v", v, err)
+	}
+	table.AdvanceRow()
+	if _, _, err := table.GetString(0); err == nil {
+		t.Errorf("Expected error for invalid UTF-8")
+	}
+	var s string
+	if err := table.Scan(&s); err == nil {
+		t.Errorf("Expected Scan error for invalid UTF-8")
+	}
+
+	table.SetValidateUTF8(false)
+	if v, _, err := table.GetString(0); v != invalid || err != nil {
+		t.Errorf("Expected lenient GetString, have %q, %v", v, err)
+	}
+}
+
+func TestSumColumn(t *testing.T) {
+	table := newTestTable(t,
+		[]testColumn{{"MODIFIED", vt_LONG}, {"PARTITION", vt_INT}, {"NAME", vt_STRING}},
+		[][]interface{}{
+			{int64(3), int32(0), "a"},
+			{nil, int32(1), "b"},
+			{int64(4), nil, nil},
+			{int64(-2), int32(3), "c"},
+		})
+	if sum, err := table.SumColumn(0); sum != 5 || err != nil {
+		t.Errorf("Bad BIGINT sum have %v, %v wants 5", sum, err)
+	}
+	if sum, err := table.SumColumn(1); sum != 4 || err != nil {
+		t.Errorf("Bad INTEGER sum have %v, %v wants 4", sum, err)
+	}
+	if _, err := table.SumColumn(2); err == nil {
+		t.Errorf("Expected error summing a STRING column")
+	}
+	if _, err := table.SumColumn(3); err == nil {
+		t.Errorf("Expected error for out of range column")
+	}
+
+	// rows already read are still summed, and none are consumed.
+	table.AdvanceRow()
+	table.AdvanceRow()
+	if sum, err := table.SumColumn(0); sum != 5 || err != nil {
+		t.Errorf("Bad sum after advancing have %v, %v wants 5", sum, err)
+	}
+	if v, _, _ := table.GetString(2); v != "b" {
+		t.Errorf("SumColumn moved the current row to %v", v)
+	}
+	table.AdvanceRow()
+	if v, _, _ := table.GetLong(0); v != 4 {
+		t.Errorf("SumColumn consumed rows, next row has %v", v)
+	}
+	if sum, err := table.unreadRows().SumColumn(0); sum != 5 || err != nil {
+		t.Errorf("Bad sum of a copy have %v, %v wants 5", sum, err)
+	}
+}
+
+func TestSumColumnAllNull(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"MODIFIED", vt_LONG}}, [][]interface{}{{nil}})
+	if sum, err := table.SumColumn(0); sum != 0 || err != nil {
+		t.Errorf("Bad sum of NULLs have %v, %v wants 0", sum, err)
+	}
+}
+
+func TestSumColumnOverflow(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"MODIFIED", vt_LONG}},
+		[][]interface{}{{int64(math.MaxInt64)}, {int64(1)}})
+	if _, err := table.SumColumn(0); err == nil {
+		t.Errorf("Expected error for an overflowing sum")
+	}
+}
+
+func TestGetTimestampNullIsNotEpoch(t *testing.T) {
+	epoch := time.Unix(0, 0).UTC()
+	ts := time.Date(2016, 2, 29, 13, 14, 15, 16000, time.UTC)
+	table := newTestTable(t, []testColumn{{"TS", vt_TIMESTAMP}},
+		[][]interface{}{{epoch}, {nil}, {ts}})
+	expected := []struct {
+		val    time.Time
+		isNull bool
+	}{
+		{epoch, false},
+		{time.Time{}, true},
+		{ts, false},
+	}
+	for _, ev := range expected {
+		table.AdvanceRow()
+		v, isNull, err := table.GetTimestamp(0)
+		if !v.Equal(ev.val) || isNull != ev.isNull || err != nil {
+			t.Errorf("Bad GetTimestamp have %v, %v, %v wants %v, %v", v, isNull, err, ev.val, ev.isNull)
+		}
+	}
+}
+
+func TestGetByte(t *testing.T) {
+	table := newTestTable(t, []testColumn{{"B", vt_TINYINT}},
+		[][]interface{}{{int8(math.MaxInt8)}, {nil}, {int8(math.MinInt8 + 1)}, {int8(0)}})
+	expected := []struct {
+		val    int8
+		isNull bool
+	}{
+		{math.MaxInt8, false},
+		{0, true},
+		{math.MinInt8 + 1, false},
+		{0, false},
+	}
+	for _, ev := range expected {
+		if !table.AdvanceRow() {
+			t.Fatalf("Expected a row")
+		}
+		v, isNull, err := table.GetByte(0)
+		if v != ev.val || isNull != ev.isNull || err != nil {
+			t.Errorf("Bad GetByte have %v, %v, %v wants %v, %v", v, isNull, err, ev.val, ev.isNull)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package voltdb
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// timeout.go bounds how long socket reads and writes may block. The
+// response reader is idle whenever no call is pending, so the read
+// timeout only runs while a response is awaited: it is armed when a
+// call is sent to an idle Conn, re-armed by each response that leaves
+// calls pending and cleared when none remain.
+
+// SetReadTimeout bounds the wait for the next response while calls
+// are pending. If it expires the connection is marked lost and the
+// pending calls fail with an error whose cause is a net.Error
+// timeout. Zero means no timeout.
+func (conn *Conn) SetReadTimeout(d time.Duration) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.readTimeout = d
+	conn.updateReadDeadline()
+}
+
+// SetWriteTimeout bounds the write of each invocation. A write that
+// times out returns an error wrapping a net.Error timeout, and fails
+// the connection, as part of the invocation may have been written.
+// Zero means no timeout.
+func (conn *Conn) SetWriteTimeout(d time.Duration) {
+	conn.mu.Lock()
+	conn.writeTimeout = d
+	conn.mu.Unlock()
+}
+
+// CallTimeout is Call bounded by timeout. If no response arrives in
+// time, CallTimeout returns context.DeadlineExceeded, a net.Error
+// timeout, and the late response is discarded when it arrives, so the
+// Conn stays usable. Unlike SetReadTimeout, it does not treat a slow
+// response as a lost connection.
+func (conn *Conn) CallTimeout(timeout time.Duration, procedure string, params ...interface{}) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return conn.CallContext(ctx, procedure, params...)
+}
+
+// updateReadDeadline arms the read deadline if calls are pending and
+// clears it otherwise. conn.mu must be held.
+func (conn *Conn) updateReadDeadline() {
+	if conn.netConn == nil {
+		return
+	}
+	if conn.readTimeout > 0 && len(conn.pending) > 0 {
+		setReadDeadline(conn.netConn, time.Now().Add(conn.readTimeout))
+	} else {
+		setReadDeadline(conn.netConn, time.Time{})
+	}
+}
+
+// setReadDeadline sets the read deadline of rwc if it has one.
+func setReadDeadline(rwc io.ReadWriteCloser, t time.Time) {
+	if d, ok := rwc.(interface{ SetReadDeadline(time.Time) error }); ok {
+		d.SetReadDeadline(t)
+	}
+}
+
+// setWriteDeadline sets the write deadline of rwc if it has one.
+func setWriteDeadline(rwc io.ReadWriteCloser, t time.Time) {
+	if d, ok := rwc.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		d.SetWriteDeadline(t)
+	}
+}
@@ -0,0 +1,255 @@
+package voltdb
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// delayServer answers each invocation of procs after delay.
+func delayServer(t *testing.T, delay time.Duration, procs ...string) *MockServer {
+	server := newMockServer(t)
+	handleEcho(server, func() { time.Sleep(delay) }, procs...)
+	return server
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func TestReadTimeout(t *testing.T) {
+	server := delayServer(t, 200*time.Millisecond, "Slow")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadTimeout(20 * time.Millisecond)
+	start := time.Now()
+	if _, err := conn.Call("Slow"); !isTimeout(err) {
+		t.Errorf("Expected a timeout error, have %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Call returned after %v", elapsed)
+	}
+}
+
+func TestReadTimeoutIdle(t *testing.T) {
+	server := delayServer(t, 5*time.Millisecond, "Fast")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadTimeout(50 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Call("Fast"); err != nil {
+			t.Fatalf("Call produced error %v", err)
+		}
+		// idle for longer than the timeout.
+		time.Sleep(100 * time.Millisecond)
+	}
+	if conn.failed() {
+		t.Errorf("Expected an idle Conn to survive the read timeout")
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	// a server that never reads fills the socket buffers.
+	block := make(chan struct{})
+	server := newTestServer(t, func(c net.Conn) {
+		<-block
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+	defer close(block)
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetWriteTimeout(20 * time.Millisecond)
+	big := make([]byte, 1<<20)
+	for i := 0; i < 64; i++ {
+		if _, err = conn.CallAsync("Big", big); err != nil {
+			break
+		}
+	}
+	if !isTimeout(err) {
+		t.Errorf("Expected a timeout error, have %v", err)
+	}
+	// the cut short invocation leaves the stream unusable.
+	if _, err := conn.CallAsync("Next"); err == nil {
+		t.Errorf("Expected the call after a write timeout to fail")
+	}
+}
+
+func TestWriteFailureCallback(t *testing.T) {
+	block := make(chan struct{})
+	server := newTestServer(t, func(c net.Conn) {
+		<-block
+		io.Copy(io.Discard, c)
+	})
+	defer server.close()
+	defer close(block)
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	// the callback runs when the write failure fails its call, and
+	// must be able to call into the Conn.
+	called := make(chan error, 1)
+	err = conn.CallWithCallback("First", nil, func(rsp *Response, err error) {
+		_, err = conn.CallAsync("Again")
+		conn.Close()
+		called <- err
+	})
+	if err != nil {
+		t.Fatalf("CallWithCallback produced error %v", err)
+	}
+	conn.SetWriteTimeout(20 * time.Millisecond)
+	big := make([]byte, 1<<20)
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 64 && err == nil; i++ {
+			_, err = conn.CallAsync("Big", big)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-called:
+		if err == nil {
+			t.Errorf("Expected the call from the callback to fail")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Callback calling into the Conn after a write failure deadlocked")
+	}
+	if err := <-done; !isTimeout(err) {
+		t.Errorf("Expected a timeout error, have %v", err)
+	}
+}
+
+func TestWriteTimeoutReconnect(t *testing.T) {
+	var connections int32
+	block := make(chan struct{})
+	server := newTestServer(t, func(c net.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			<-block
+			return
+		}
+		echoServe(c)
+	})
+	defer server.close()
+	defer close(block)
+
+	conn, err := NewConnection("user", "", server.addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	conn.SetRetryPolicy(&RetryPolicy{3, time.Millisecond, 10 * time.Millisecond})
+	conn.SetWriteTimeout(20 * time.Millisecond)
+	big := make([]byte, 1<<20)
+	for i := 0; i < 64; i++ {
+		if _, err = conn.CallAsync("Big", big); err != nil {
+			break
+		}
+	}
+	if !isTimeout(err) {
+		t.Fatalf("Expected a timeout error, have %v", err)
+	}
+	conn.SetWriteTimeout(0)
+	rsp, err := conn.CallIdempotent("Next")
+	if err != nil {
+		t.Fatalf("CallIdempotent produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Next" {
+		t.Errorf("Expected Next on the new connection have %v", v)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	server := delayServer(t, 100*time.Millisecond, "Slow", "Next")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	if _, err := conn.CallTimeout(20*time.Millisecond, "Slow"); !isTimeout(err) {
+		t.Errorf("Expected a timeout error, have %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Errorf("CallTimeout returned after %v", elapsed)
+	}
+
+	// the late response to Slow must not be taken for this one.
+	rsp, err := conn.CallTimeout(time.Second, "Next")
+	if err != nil {
+		t.Fatalf("CallTimeout produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Next" {
+		t.Errorf("Expected Next have %v", v)
+	}
+	if conn.failed() {
+		t.Errorf("Expected the Conn to survive a call timeout")
+	}
+}
+
+func TestLateResponseDiscarded(t *testing.T) {
+	server := delayServer(t, 100*time.Millisecond, "Slow", "Next")
+	defer server.Close()
+
+	conn, err := NewConnection("user", "", server.Addr())
+	if err != nil {
+		t.Fatalf("NewConnection produced error %v", err)
+	}
+	defer conn.Close()
+	// every call reuses one handle, so a late response delivered to
+	// the wrong call would be taken for its own.
+	conn.SetHandleGenerator(func() int64 { return 42 })
+	if _, err := conn.CallTimeout(20*time.Millisecond, "Slow"); !isTimeout(err) {
+		t.Fatalf("Expected a timeout error, have %v", err)
+	}
+	if _, err := conn.CallTimeout(time.Second, "Next"); err == nil {
+		t.Errorf("Expected error reusing the handle of a call awaiting its late response")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for conn.Stats().LateResponses == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if late := conn.Stats().LateResponses; late != 1 {
+		t.Fatalf("Bad late responses have %v wants 1", late)
+	}
+	rsp, err := conn.CallTimeout(time.Second, "Next")
+	if err != nil {
+		t.Fatalf("CallTimeout produced error %v", err)
+	}
+	table := rsp.Table(0)
+	table.AdvanceRow()
+	if v, _, _ := table.GetString(0); v != "Next" {
+		t.Errorf("Expected Next have %v", v)
+	}
+	if conn.failed() {
+		t.Errorf("Expected the Conn to survive a late response")
+	}
+}